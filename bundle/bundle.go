@@ -0,0 +1,276 @@
+package bundle
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/onepanelio/cli/config"
+	"github.com/onepanelio/cli/files"
+	"github.com/onepanelio/cli/util"
+	"gopkg.in/yaml.v2"
+)
+
+// Layout of a bundle.
+const (
+	MetadataFileName        = "bundle.yaml"
+	ManifestsDirectoryName  = "manifests"
+	RenderedDirectoryName   = "rendered"
+	ApplicationYamlFileName = "application.kubernetes.yaml"
+	KubernetesYamlFileName  = "kubernetes.yaml"
+	ImagesFileName          = "images.txt"
+	ImagesDirectoryName     = "images"
+	ConfigFileName          = "config.yaml"
+	ParamsFileName          = "params.yaml"
+	SourceConfigFileName    = "cli_config.yaml"
+)
+
+// Metadata is written to bundle.yaml and describes what the bundle was created with.
+type Metadata struct {
+	CLIVersion   string    `yaml:"cliVersion"`
+	ManifestsTag string    `yaml:"manifestsTag,omitempty"`
+	Manifests    string    `yaml:"manifests"` // name of the directory in manifests/
+	CreatedAt    time.Time `yaml:"createdAt"`
+}
+
+// CreateOptions are the files and rendered YAML that go into a bundle.
+type CreateOptions struct {
+	Config           *config.Config
+	SourceConfigPath string // cli_config.yaml, optional
+	ManifestsTag     string
+	ApplicationYaml  string // the rendered common/application/base component, applied first
+	KubernetesYaml   string // the rest of the rendered components
+	ImagesDirectory  string // directory with image tarballs named by ImageArchiveName, optional
+	Output           string
+}
+
+// Bundle is an extracted bundle.
+type Bundle struct {
+	Path     string
+	Metadata Metadata
+}
+
+// ImageArchiveName is the name of the tarball of image, e.g. docker save onepanel/core:v0.10.0 > onepanel_core_v0.10.0.tar
+func ImageArchiveName(image string) string {
+	return strings.NewReplacer("/", "_", ":", "_", "@", "_").Replace(image) + ".tar"
+}
+
+// Create writes a bundle to options.Output. The images referenced in the rendered YAML are listed in images.txt.
+// If options.ImagesDirectory is set, the matching image tarballs are added, and the images without one are returned.
+// The bundle contains the params, so it is only readable by the current user.
+func Create(options *CreateOptions) (missingImages []string, err error) {
+	staging, err := ioutil.TempDir("", "opctl-bundle")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	manifestsName := filepath.Base(filepath.Clean(options.Config.Spec.ManifestsRepo))
+	if err := files.CopyDir(options.Config.Spec.ManifestsRepo, filepath.Join(staging, ManifestsDirectoryName, manifestsName)); err != nil {
+		return nil, err
+	}
+
+	// The params files are bundled merged, as one file.
+	params, err := util.LoadParams(options.Config)
+	if err != nil {
+		return nil, err
+	}
+	if err := params.WriteToFile(filepath.Join(staging, ParamsFileName)); err != nil {
+		return nil, err
+	}
+
+	if options.SourceConfigPath != "" {
+		if err := files.CopyFile(options.SourceConfigPath, filepath.Join(staging, SourceConfigFileName)); err != nil {
+			return nil, err
+		}
+	}
+
+	// Paths in the bundled config are relative to the bundle, Bundle.Config resolves them after extracting.
+	bundleConfig := *options.Config
+	bundleConfig.Spec.ManifestsRepo = filepath.ToSlash(filepath.Join(ManifestsDirectoryName, manifestsName))
+	bundleConfig.Spec.Params = config.ParamsFiles{ParamsFileName}
+	if err := writeYaml(filepath.Join(staging, ConfigFileName), bundleConfig); err != nil {
+		return nil, err
+	}
+
+	metadata := Metadata{
+		CLIVersion:   config.CLIVersion,
+		ManifestsTag: options.ManifestsTag,
+		Manifests:    manifestsName,
+		CreatedAt:    time.Now().UTC(),
+	}
+	if err := writeYaml(filepath.Join(staging, MetadataFileName), metadata); err != nil {
+		return nil, err
+	}
+
+	renderedPath := filepath.Join(staging, RenderedDirectoryName)
+	if err := os.MkdirAll(renderedPath, os.ModePerm); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(renderedPath, ApplicationYamlFileName), []byte(options.ApplicationYaml), 0644); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(renderedPath, KubernetesYamlFileName), []byte(options.KubernetesYaml), 0644); err != nil {
+		return nil, err
+	}
+
+	images, err := util.ContainerImages([]byte(options.ApplicationYaml + "\n---\n" + options.KubernetesYaml))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the images of the rendered YAML: %v", err.Error())
+	}
+	imageList := ""
+	for _, image := range images {
+		imageList += image + "\n"
+	}
+	if err := ioutil.WriteFile(filepath.Join(staging, ImagesFileName), []byte(imageList), 0644); err != nil {
+		return nil, err
+	}
+
+	if options.ImagesDirectory != "" {
+		missingImages, err = copyImageArchives(images, options.ImagesDirectory, filepath.Join(staging, ImagesDirectoryName))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := files.Tar(staging, options.Output); err != nil {
+		return nil, err
+	}
+
+	return missingImages, os.Chmod(options.Output, 0600)
+}
+
+// copyImageArchives copies the tarball of each image from imagesDirectory to dest and returns the images without one.
+func copyImageArchives(images []string, imagesDirectory, dest string) (missingImages []string, err error) {
+	if err := os.MkdirAll(dest, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	for _, image := range images {
+		name := ImageArchiveName(image)
+
+		exists, err := files.Exists(filepath.Join(imagesDirectory, name))
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			missingImages = append(missingImages, image)
+			continue
+		}
+
+		if err := files.CopyFile(filepath.Join(imagesDirectory, name), filepath.Join(dest, name)); err != nil {
+			return nil, err
+		}
+	}
+
+	return missingImages, nil
+}
+
+func writeYaml(path string, value interface{}) error {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Extract extracts the bundle archive to dest. Anything already in dest is removed first.
+func Extract(archive, dest string) (*Bundle, error) {
+	if err := os.RemoveAll(dest); err != nil {
+		return nil, err
+	}
+
+	if _, err := files.Untar(archive, dest); err != nil {
+		return nil, fmt.Errorf("unable to extract bundle %v: %v", archive, err.Error())
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dest, MetadataFileName))
+	if err != nil {
+		return nil, fmt.Errorf("%v is not a bundle, %v is missing", archive, MetadataFileName)
+	}
+
+	bundle := &Bundle{
+		Path: dest,
+	}
+	if err := yaml.Unmarshal(data, &bundle.Metadata); err != nil {
+		return nil, fmt.Errorf("unable to read %v: %v", MetadataFileName, err.Error())
+	}
+
+	return bundle, nil
+}
+
+// ManifestsPath is the directory of the bundled manifests.
+func (b *Bundle) ManifestsPath() string {
+	return filepath.Join(b.Path, ManifestsDirectoryName, b.Metadata.Manifests)
+}
+
+// ParamsPath is the bundled params.yaml.
+func (b *Bundle) ParamsPath() string {
+	return filepath.Join(b.Path, ParamsFileName)
+}
+
+// SourceConfigPath is the bundled cli_config.yaml. The file does not exist if there was none when the bundle was created.
+func (b *Bundle) SourceConfigPath() string {
+	return filepath.Join(b.Path, SourceConfigFileName)
+}
+
+// ApplicationYamlPath is the rendered YAML that is applied first.
+func (b *Bundle) ApplicationYamlPath() string {
+	return filepath.Join(b.Path, RenderedDirectoryName, ApplicationYamlFileName)
+}
+
+// KubernetesYamlPath is the rendered YAML that is applied once the application controller runs.
+func (b *Bundle) KubernetesYamlPath() string {
+	return filepath.Join(b.Path, RenderedDirectoryName, KubernetesYamlFileName)
+}
+
+// ImagesPath is the directory of the bundled image tarballs.
+func (b *Bundle) ImagesPath() string {
+	return filepath.Join(b.Path, ImagesDirectoryName)
+}
+
+// Images returns the images listed in images.txt.
+func (b *Bundle) Images() ([]string, error) {
+	file, err := os.Open(filepath.Join(b.Path, ImagesFileName))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var images []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			images = append(images, line)
+		}
+	}
+
+	return images, scanner.Err()
+}
+
+// Config returns the bundled config with the manifests and params pointing into the extracted bundle.
+func (b *Bundle) Config() (*config.Config, error) {
+	data, err := ioutil.ReadFile(filepath.Join(b.Path, ConfigFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	bundleConfig, _, err := config.FromBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %v: %v", ConfigFileName, err.Error())
+	}
+
+	bundleConfig.Spec.ManifestsRepo = b.ManifestsPath()
+	bundleConfig.Spec.Params = config.ParamsFiles{b.ParamsPath()}
+
+	if err := bundleConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	return bundleConfig, nil
+}
@@ -0,0 +1,69 @@
+package bundle
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onepanelio/cli/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateAndExtract(t *testing.T) {
+	dir, err := ioutil.TempDir("", "opctl-bundle-test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	manifestsPath := filepath.Join(dir, "v0.10.0")
+	assert.Nil(t, os.MkdirAll(filepath.Join(manifestsPath, "istio"), os.ModePerm))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(manifestsPath, "istio", "kustomization.yaml"), []byte("resources: []\n"), 0644))
+
+	paramsPath := filepath.Join(dir, "params.yaml")
+	assert.Nil(t, ioutil.WriteFile(paramsPath, []byte("application:\n  fqdn: app.test\n"), 0644))
+
+	imagesPath := filepath.Join(dir, "images")
+	assert.Nil(t, os.MkdirAll(imagesPath, os.ModePerm))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(imagesPath, "onepanel_core_v0.10.0.tar"), []byte("image"), 0644))
+
+	output := filepath.Join(dir, "bundle.tar.gz")
+	missingImages, err := Create(&CreateOptions{
+		Config: &config.Config{
+			ApiVersion: config.APIVersion,
+			Kind:       config.Kind,
+			Spec: config.ConfigSpec{
+				ManifestsRepo: manifestsPath,
+				Params:        config.ParamsFiles{paramsPath},
+				Components:    []string{"istio"},
+			},
+		},
+		ManifestsTag:    "v0.10.0",
+		ApplicationYaml: "kind: StatefulSet\nspec:\n  template:\n    spec:\n      containers:\n      - image: onepanel/core:v0.10.0\n",
+		KubernetesYaml:  "kind: Deployment\nspec:\n  template:\n    spec:\n      containers:\n      - image: busybox:1.31\n",
+		ImagesDirectory: imagesPath,
+		Output:          output,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"busybox:1.31"}, missingImages)
+
+	info, err := os.Stat(output)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	bundle, err := Extract(output, filepath.Join(dir, "extracted"))
+	assert.Nil(t, err)
+	assert.Equal(t, "v0.10.0", bundle.Metadata.ManifestsTag)
+
+	bundleConfig, err := bundle.Config()
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "extracted", "manifests", "v0.10.0"), bundleConfig.Spec.ManifestsRepo)
+	assert.Equal(t, config.ParamsFiles{filepath.Join(dir, "extracted", "params.yaml")}, bundleConfig.Spec.Params)
+	assert.Equal(t, []string{"istio"}, bundleConfig.Spec.Components)
+
+	images, err := bundle.Images()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"busybox:1.31", "onepanel/core:v0.10.0"}, images)
+
+	_, err = os.Stat(filepath.Join(bundle.ImagesPath(), "onepanel_core_v0.10.0.tar"))
+	assert.Nil(t, err)
+}
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	opConfig "github.com/onepanelio/cli/config"
 	"github.com/onepanelio/cli/util"
 	"github.com/spf13/cobra"
 )
@@ -21,13 +20,13 @@ var statusCmd = &cobra.Command{
 	Long:    "Check deployment status by checking pods statuses.",
 	Example: "status",
 	Run: func(cmd *cobra.Command, args []string) {
-		configFilePath := "config.yaml"
-		config, err := opConfig.FromFile(configFilePath)
+		configFilePath := currentConfigFilePath()
+		config, err := loadConfig(configFilePath)
 		if err != nil {
 			fmt.Printf("Unable to read configuration file: %v", err.Error())
 			return
 		}
-		yamlFile, err := util.LoadDynamicYamlFromFile(config.Spec.Params)
+		yamlFile, err := util.LoadParams(config)
 		if err != nil {
 			fmt.Println("Error parsing configuration file.")
 			return
@@ -51,11 +50,40 @@ var statusCmd = &cobra.Command{
 			return
 		}
 
-		util.GetClusterIp(url)
+		util.GetClusterIp(url, yamlFile)
+
+		verifyDNS(yamlFile, url)
 	},
 }
 
+// DNSServer is the DNS server used to verify the deployment's records. The system resolvers are used if empty.
+var DNSServer string
+
 func init() {
 	rootCmd.AddCommand(appCmd)
 	appCmd.AddCommand(statusCmd)
+	statusCmd.Flags().StringVarP(&DNSServer, "dns-server", "", "", "DNS server, host[:port], used to verify the DNS records. Defaults to the system resolvers")
+}
+
+// verifyDNS checks that application.fqdn and its wildcard domain resolve to the ingress gateway
+// and that the web UI at url responds, then prints the results.
+func verifyDNS(yamlFile *util.DynamicYaml, url string) {
+	target, err := util.IngressGatewayAddress()
+	if err != nil {
+		fmt.Printf("[error] %v\n", err.Error())
+		return
+	}
+	if target == "" {
+		fmt.Println("The ingress gateway does not have an external IP or hostname yet, skipping DNS verification.")
+		return
+	}
+
+	fqdn := yamlFile.GetValue("application.fqdn").Value
+	report := util.NewDNSVerifier(DNSServer).Verify(fqdn, target, url)
+
+	fmt.Println("Verifying DNS...")
+	fmt.Print(report.String())
+	if report.HasFailures() {
+		fmt.Println("\nDNS changes can take a while to propagate. Check again with `opctl app status`.")
+	}
 }
@@ -10,17 +10,21 @@ import (
 
 	"github.com/onepanelio/cli/util"
 
+	"github.com/onepanelio/cli/bundle"
 	opConfig "github.com/onepanelio/cli/config"
 	"github.com/onepanelio/cli/files"
 	"github.com/spf13/cobra"
 )
 
+// SkipPreflight disables the doctor checks run at the start of apply.
+var SkipPreflight bool
+
 // applyCmd represents the apply command
 var applyCmd = &cobra.Command{
 	Use:   "apply",
 	Short: "Applies application YAML to your Kubernetes cluster.",
 	Run: func(cmd *cobra.Command, args []string) {
-		configFilePath := "config.yaml"
+		configFilePath := currentConfigFilePath()
 
 		fmt.Printf("Starting deployment...\n\n")
 
@@ -29,47 +33,68 @@ var applyCmd = &cobra.Command{
 			return
 		}
 
-		config, err := opConfig.FromFile(configFilePath)
-		if err != nil {
-			fmt.Printf("Unable to read configuration file: %v", err.Error())
-			return
-		}
-
-		overlayComponentFirst := filepath.Join("common/application/base")
-		baseOverlayComponent := config.GetOverlayComponent(overlayComponentFirst)
-		applicationBaseKustomizeTemplate := TemplateFromSimpleOverlayedComponents(baseOverlayComponent)
-		applicationResult, err := GenerateKustomizeResult(*config, applicationBaseKustomizeTemplate)
-		if err != nil {
-			log.Printf("Error generating result %v", err.Error())
-			return
-		}
+		var applyBundle *bundle.Bundle
+		var config *opConfig.Config
+		var err error
+		if BundleFile != "" {
+			// The YAML of a bundle is already rendered, the params have to be set when the bundle is created.
+			if len(ParamSets) != 0 || len(ParamSetFiles) != 0 {
+				fmt.Println("--set and --set-file can't be used with --bundle, use them with opctl bundle create")
+				return
+			}
 
-		applicationKubernetesYamlFilePath := filepath.Join(".onepanel/application.kubernetes.yaml")
+			applyBundle, err = bundle.Extract(BundleFile, bundleFilePath())
+			if err != nil {
+				fmt.Printf("Unable to read bundle: %v\n", err.Error())
+				return
+			}
 
-		existsApp, err := files.Exists(applicationKubernetesYamlFilePath)
+			config, err = applyBundle.Config()
+		} else {
+			config, err = loadConfig(configFilePath)
+		}
 		if err != nil {
-			log.Printf("Unable to check if file %v exists", applicationKubernetesYamlFilePath)
+			fmt.Printf("Unable to read configuration file: %v", err.Error())
 			return
 		}
 
-		var applicationKubernetesFile *os.File = nil
-		if !existsApp {
-			applicationKubernetesFile, err = os.Create(applicationKubernetesYamlFilePath)
+		if !SkipPreflight {
+			report, err := runPreflightChecks(config)
 			if err != nil {
-				log.Printf("Unable to create file: error %v", err.Error())
+				fmt.Printf("Unable to run preflight checks: %v\n", err.Error())
 				return
 			}
+
+			fmt.Print(report.String())
+			if report.HasFailures() {
+				fmt.Println("\nPreflight checks failed. Fix the issues above or skip the checks with --skip-preflight.")
+				return
+			}
+			fmt.Println()
+		}
+
+		applicationKubernetesYamlFilePath := filepath.Join(currentStatePath(), "application.kubernetes.yaml")
+		finalKubernetesYamlFilePath := filepath.Join(currentStatePath(), "kubernetes.yaml")
+
+		if applyBundle != nil {
+			applicationKubernetesYamlFilePath = applyBundle.ApplicationYamlPath()
+			finalKubernetesYamlFilePath = applyBundle.KubernetesYamlPath()
 		} else {
-			applicationKubernetesFile, err = os.OpenFile(applicationKubernetesYamlFilePath, os.O_RDWR|os.O_TRUNC, 0)
+			applicationResult, result, err := generateApplyYaml(config)
 			if err != nil {
-				log.Printf("Unable to open file: error %v", err.Error())
+				log.Printf("Error generating result %v", err.Error())
 				return
 			}
-		}
 
-		if _, err := applicationKubernetesFile.WriteString(applicationResult); err != nil {
-			log.Printf("Error writing to temporary file: %v", err.Error())
-			return
+			if err := writeKubernetesYaml(applicationKubernetesYamlFilePath, applicationResult); err != nil {
+				log.Printf("%v", err.Error())
+				return
+			}
+
+			if err := writeKubernetesYaml(finalKubernetesYamlFilePath, result); err != nil {
+				log.Printf("%v", err.Error())
+				return
+			}
 		}
 
 		resApp := ""
@@ -122,42 +147,6 @@ var applyCmd = &cobra.Command{
 		}
 
 		//Apply the rest of the yaml
-		kustomizeTemplate := TemplateFromSimpleOverlayedComponents(config.GetOverlayComponents(overlayComponentFirst))
-
-		result, err := GenerateKustomizeResult(*config, kustomizeTemplate)
-		if err != nil {
-			log.Printf("Error generating result %v", err.Error())
-			return
-		}
-
-		finalKubernetesYamlFilePath := filepath.Join(".onepanel/kubernetes.yaml")
-
-		exists, err := files.Exists(finalKubernetesYamlFilePath)
-		if err != nil {
-			log.Printf("Unable to check if file %v exists", finalKubernetesYamlFilePath)
-			return
-		}
-
-		var finalKubernetesFile *os.File = nil
-		if !exists {
-			finalKubernetesFile, err = os.Create(finalKubernetesYamlFilePath)
-			if err != nil {
-				log.Printf("Unable to create file: error %v", err.Error())
-				return
-			}
-		} else {
-			finalKubernetesFile, err = os.OpenFile(finalKubernetesYamlFilePath, os.O_RDWR|os.O_TRUNC, 0)
-			if err != nil {
-				log.Printf("Unable to open file: error %v", err.Error())
-				return
-			}
-		}
-
-		if _, err := finalKubernetesFile.WriteString(result); err != nil {
-			log.Printf("Error writing to temporary file: %v", err.Error())
-			return
-		}
-
 		res := ""
 		errRes := ""
 
@@ -178,7 +167,7 @@ var applyCmd = &cobra.Command{
 			log.Printf("%v", errRes)
 		}
 
-		yamlFile, err := util.LoadDynamicYamlFromFile(config.Spec.Params)
+		yamlFile, err := util.LoadParams(config)
 		if err != nil {
 			fmt.Println("Error parsing configuration file.")
 			return
@@ -218,7 +207,12 @@ var applyCmd = &cobra.Command{
 				return
 			}
 
-			util.GetClusterIp(url)
+			util.GetClusterIp(url, yamlFile)
+
+			// The DNS checks query resolvers and the deployed url, which an offline install can't reach.
+			if applyBundle == nil {
+				verifyDNS(yamlFile, url)
+			}
 		}
 	},
 }
@@ -226,6 +220,59 @@ var applyCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(applyCmd)
 	applyCmd.Flags().BoolVarP(&Dev, "dev", "", false, "Sets conditions to allow development testing.")
+	applyCmd.Flags().StringVarP(&DNSServer, "dns-server", "", "", "DNS server, host[:port], used to verify the DNS records. Defaults to the system resolvers")
+	applyCmd.Flags().BoolVarP(&SkipPreflight, "skip-preflight", "", false, "Skip the cluster checks done by the doctor command before applying.")
+	applyCmd.Flags().StringVarP(&BundleFile, "bundle", "", "", "Apply the YAML rendered in a bundle created with opctl bundle create, instead of config.yaml.")
+	applyCmd.Flags().StringVarP(&ImageRegistryPrefix, "image-registry", "", "", imageRegistryFlagUsage)
+	addParamOverrideFlags(applyCmd)
+}
+
+// generateApplyYaml renders the application component, which is applied first as the rest depends on its controller,
+// and the rest of the components in config.
+func generateApplyYaml(config *opConfig.Config) (applicationYaml string, kubernetesYaml string, err error) {
+	overlayComponentFirst := filepath.Join("common/application/base")
+	baseOverlayComponent := config.GetOverlayComponent(overlayComponentFirst)
+	applicationBaseKustomizeTemplate := TemplateFromSimpleOverlayedComponents(baseOverlayComponent)
+	applicationYaml, err = GenerateKustomizeResult(*config, applicationBaseKustomizeTemplate)
+	if err != nil {
+		return "", "", err
+	}
+
+	kustomizeTemplate := TemplateFromSimpleOverlayedComponents(config.GetOverlayComponents(overlayComponentFirst))
+	kubernetesYaml, err = GenerateKustomizeResult(*config, kustomizeTemplate)
+	if err != nil {
+		return "", "", err
+	}
+
+	return applicationYaml, kubernetesYaml, nil
+}
+
+// writeKubernetesYaml replaces the content of the file at path with content.
+func writeKubernetesYaml(path string, content string) error {
+	exists, err := files.Exists(path)
+	if err != nil {
+		return fmt.Errorf("Unable to check if file %v exists", path)
+	}
+
+	var file *os.File = nil
+	if !exists {
+		file, err = os.Create(path)
+		if err != nil {
+			return fmt.Errorf("Unable to create file: error %v", err.Error())
+		}
+	} else {
+		file, err = os.OpenFile(path, os.O_RDWR|os.O_TRUNC, 0)
+		if err != nil {
+			return fmt.Errorf("Unable to open file: error %v", err.Error())
+		}
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(content); err != nil {
+		return fmt.Errorf("Error writing to temporary file: %v", err.Error())
+	}
+
+	return nil
 }
 
 func getPodInfo(podName string, podNamespace string) (res string, errMessage string, err error) {
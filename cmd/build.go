@@ -11,8 +11,10 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	yaml2 "gopkg.in/yaml.v3"
 
@@ -30,19 +32,29 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+var (
+	// ImageRegistryPrefix is the registry the images are relocated to, it overrides imageRegistry.prefix of the params.
+	ImageRegistryPrefix string
+	// relocatedImages maps the images relocated by build to their destination.
+	relocatedImages = make(map[string]string)
+)
+
+// imageRegistryFlagUsage is shared by the commands that build.
+const imageRegistryFlagUsage = "Relocate all container images to this registry, e.g. registry.example.com/onepanel. Overrides imageRegistry.prefix in the params"
+
 // generateCmd represents the generate command
 var generateCmd = &cobra.Command{
 	Use:   "build",
 	Short: "Builds application YAML for preview.",
 	Run: func(cmd *cobra.Command, args []string) {
-		configFilePath := "config.yaml"
+		configFilePath := currentConfigFilePath()
 
 		if len(args) > 1 {
 			configFilePath = args[0]
 			return
 		}
 
-		config, err := opConfig.FromFile(configFilePath)
+		config, err := loadConfig(configFilePath)
 		if err != nil {
 			fmt.Printf("Unable to read configuration file: %v", err.Error())
 			fmt.Println() // This gives us a newline as we get an extra "exiting" message
@@ -65,60 +77,117 @@ var generateCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(generateCmd)
 	generateCmd.Flags().BoolVarP(&Dev, "dev", "", false, "Sets conditions to allow development testing.")
+	generateCmd.Flags().StringVarP(&ImageRegistryPrefix, "image-registry", "", "", imageRegistryFlagUsage)
+	addParamOverrideFlags(generateCmd)
 }
 
 // Given the path to the manifests, and a kustomize config, creates the final kustomization file.
 // It does this by copying the manifests into a temporary directory, inserting the kustomize template
 // and running the kustomize command
 func GenerateKustomizeResult(config opConfig.Config, kustomizeTemplate template.Kustomize) (string, error) {
-	manifestPath := config.Spec.ManifestsRepo
-	localManifestsCopyPath := filepath.Join(".onepanel/manifests/cache")
+	localManifestsCopyPath, imageRegistry, secrets, err := prepareManifests(config, &kustomizeTemplate)
+	if err != nil {
+		return "", err
+	}
 
-	exists, err := files.Exists(localManifestsCopyPath)
+	rm, err := buildManifests(localManifestsCopyPath, kustomizeTemplate, imageRegistry)
 	if err != nil {
 		return "", err
 	}
 
-	if exists {
-		if err := os.RemoveAll(localManifestsCopyPath); err != nil {
-			return "", err
-		}
+	for _, res := range rm.Resources() {
+		object := res.Map()
+		secrets.RevealObject(object)
+		res.SetMap(object)
 	}
 
-	if err := files.CopyDir(manifestPath, localManifestsCopyPath); err != nil {
+	kustYaml, err := rm.AsYaml()
+	if err != nil {
 		return "", err
 	}
 
+	return string(kustYaml), nil
+}
+
+// buildManifests writes kustomizeTemplate as the kustomization of the manifests prepared by prepareManifests and runs kustomize.
+// The images are relocated if imageRegistry is set.
+func buildManifests(localManifestsCopyPath string, kustomizeTemplate template.Kustomize, imageRegistry *util.ImageRegistry) (resmap.ResMap, error) {
 	localKustomizePath := filepath.Join(localManifestsCopyPath, "kustomization.yaml")
 	if _, err := files.DeleteIfExists(localKustomizePath); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	newFile, err := os.Create(localKustomizePath)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	defer newFile.Close()
 
 	kustomizeYaml, err := yaml.Marshal(kustomizeTemplate)
 	if err != nil {
 		log.Printf("Error yaml. Error %v", err.Error())
-		return "", err
+		return nil, err
 	}
 
 	_, err = newFile.Write(kustomizeYaml)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	yamlFile, err := util.LoadDynamicYamlFromFile(config.Spec.Params)
+	rm, err := runKustomizeBuild(localManifestsCopyPath)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	if imageRegistry != nil {
+		if err := relocateImages(rm, imageRegistry); err != nil {
+			return nil, err
+		}
+	}
+
+	return rm, nil
+}
+
+// prepareManifests copies the manifests into a temporary directory and replaces the placeholders with the params.
+// Resources added for the params, like the TLS secret, are added to kustomizeTemplate.
+// The secret references of the params are replaced with tokens, the returned secrets reveal them once the manifests are built.
+func prepareManifests(config opConfig.Config, kustomizeTemplate *template.Kustomize) (localManifestsCopyPath string, imageRegistry *util.ImageRegistry, secrets *util.Secrets, err error) {
+	manifestPath := config.Spec.ManifestsRepo
+	localManifestsCopyPath = filepath.Join(manifestsFilePath(), "cache")
+
+	exists, err := files.Exists(localManifestsCopyPath)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	if exists {
+		if err := os.RemoveAll(localManifestsCopyPath); err != nil {
+			return "", nil, nil, err
+		}
+	}
+
+	if err := files.CopyDir(manifestPath, localManifestsCopyPath); err != nil {
+		return "", nil, nil, err
+	}
+
+	yamlFile, err := util.LoadParams(&config)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	secrets, err = util.ConcealSecretRefs(yamlFile)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	if err := addTLSSecretResource(yamlFile, secrets, localManifestsCopyPath, kustomizeTemplate); err != nil {
+		return "", nil, nil, err
 	}
 
 	fqdn := yamlFile.GetValue("application.fqdn").Value
 	cloudSettings, err := util.LoadDynamicYamlFromFile(filepath.Join(config.Spec.ManifestsRepo, "vars", "onepanel-config-map-hidden.env"))
 	if err != nil {
-		return "", err
+		return "", nil, nil, err
 	}
 
 	applicationApiPath := cloudSettings.GetValue("applicationCloudApiPath").Value
@@ -169,18 +238,18 @@ func GenerateKustomizeResult(config opConfig.Config, kustomizeTemplate template.
 		metalLbAddressesConfigMapStr := generateMetalLbAddresses(yamlFile.GetValue("metalLb.addresses").Content)
 		yamlFile.PutWithSeparator("metalLbAddresses", metalLbAddressesConfigMapStr, ".")
 
-		metalLbSecretKey, err := bcrypt.GenerateFromPassword([]byte(rand.String(128)), bcrypt.DefaultCost)
+		metalLbSecretKey, err := loadMetalLbSecretKey(yamlFile)
 		if err != nil {
-			return "", err
+			return "", nil, nil, err
 		}
-		yamlFile.PutWithSeparator("metalLbSecretKey", base64.StdEncoding.EncodeToString(metalLbSecretKey), ".")
+		yamlFile.PutWithSeparator("metalLbSecretKey", metalLbSecretKey, ".")
 	}
 
 	_, artifactRepositoryNode := yamlFile.Get("artifactRepository")
 	artifactRepositoryConfig := v1.ArtifactRepositoryProvider{}
 	err = artifactRepositoryNode.Decode(&artifactRepositoryConfig)
 	if err != nil {
-		return "", err
+		return "", nil, nil, err
 	}
 	if artifactRepositoryConfig.S3 != nil {
 		artifactRepositoryConfig.S3.AccessKeySecret.Key = "artifactRepositoryS3AccessKey"
@@ -189,29 +258,35 @@ func GenerateKustomizeResult(config opConfig.Config, kustomizeTemplate template.
 		artifactRepositoryConfig.S3.SecretKeySecret.Name = "$(artifactRepositoryS3SecretKeySecretName)"
 		yamlStr, err := artifactRepositoryConfig.S3.MarshalToYaml()
 		if err != nil {
-			return "", err
+			return "", nil, nil, err
 		}
 		yamlFile.Put("artifactRepositoryProvider", yamlStr)
 	} else if artifactRepositoryConfig.GCS != nil {
 		yamlConfigMap, err := artifactRepositoryConfig.GCS.MarshalToYaml()
 		if err != nil {
-			return "", err
+			return "", nil, nil, err
 		}
 
 		yamlFile.Put("artifactRepositoryProvider", yamlConfigMap)
 	} else {
-		return "", errors.New("unsupported artifactRepository configuration")
+		return "", nil, nil, errors.New("unsupported artifactRepository configuration")
+	}
+
+	imageRegistry, err = loadImageRegistry(yamlFile)
+	if err != nil {
+		return "", nil, nil, err
 	}
+
 	flatMap := yamlFile.FlattenToKeyValue(util.LowerCamelCaseFlatMapKeyFormatter)
 	if err := mapLinkedVars(flatMap, localManifestsCopyPath, &config); err != nil {
-		return "", err
+		return "", nil, nil, err
 	}
 
 	//Read workflow-config-map-hidden for the rest of the values
 	workflowEnvHiddenPath := filepath.Join(localManifestsCopyPath, "vars", "workflow-config-map-hidden.env")
 	workflowEnvCont, workflowEnvFileErr := ioutil.ReadFile(workflowEnvHiddenPath)
 	if workflowEnvFileErr != nil {
-		return "", workflowEnvFileErr
+		return "", nil, nil, workflowEnvFileErr
 	}
 	workflowEnvContStr := string(workflowEnvCont)
 	//Add these keys and values
@@ -238,20 +313,20 @@ func GenerateKustomizeResult(config opConfig.Config, kustomizeTemplate template.
 		artifactRepositoryS3AccessKeySecretName, ok := flatMap["artifactRepositoryS3AccessKeySecretName"].(string)
 		if !ok {
 			if err != nil {
-				return "", err
+				return "", nil, nil, err
 			}
 		}
 		artifactRepositoryS3SecretKeySecretName, ok := flatMap["artifactRepositoryS3SecretKeySecretName"].(string)
 		if !ok {
 			if err != nil {
-				return "", err
+				return "", nil, nil, err
 			}
 		}
 		artifactRepositoryConfig.S3.AccessKeySecret.Name = artifactRepositoryS3AccessKeySecretName
 		artifactRepositoryConfig.S3.SecretKeySecret.Name = artifactRepositoryS3SecretKeySecretName
 		yamlStr, err := artifactRepositoryConfig.S3.MarshalToYaml()
 		if err != nil {
-			return "", err
+			return "", nil, nil, err
 		}
 		flatMap["artifactRepositoryProvider"] = yamlStr
 	}
@@ -264,11 +339,11 @@ func GenerateKustomizeResult(config opConfig.Config, kustomizeTemplate template.
 			//Clear previous env file
 			paramsPath := filepath.Join(localManifestsCopyPath, "vars", "workflow-config-map.env")
 			if _, err := files.DeleteIfExists(paramsPath); err != nil {
-				return "", err
+				return "", nil, nil, err
 			}
 			paramsFile, err := os.Create(paramsPath)
 			if err != nil {
-				return "", err
+				return "", nil, nil, err
 			}
 			var stringToWrite = fmt.Sprintf("%v=%v\n%v=%v\n%v=%v\n%v=%v\n",
 				"artifactRepositoryBucket", flatMap["artifactRepositoryS3Bucket"],
@@ -278,7 +353,7 @@ func GenerateKustomizeResult(config opConfig.Config, kustomizeTemplate template.
 			)
 			_, err = paramsFile.WriteString(stringToWrite)
 			if err != nil {
-				return "", err
+				return "", nil, nil, err
 			}
 		} else {
 			log.Fatal("Missing required values in params.yaml, artifactRepository. Check bucket, endpoint, or insecure.")
@@ -289,11 +364,11 @@ func GenerateKustomizeResult(config opConfig.Config, kustomizeTemplate template.
 		//Clear previous env file
 		paramsPath := filepath.Join(localManifestsCopyPath, "vars", "logging-config-map.env")
 		if _, err := files.DeleteIfExists(paramsPath); err != nil {
-			return "", err
+			return "", nil, nil, err
 		}
 		paramsFile, err := os.Create(paramsPath)
 		if err != nil {
-			return "", err
+			return "", nil, nil, err
 		}
 		var stringToWrite = fmt.Sprintf("%v=%v\n%v=%v\n",
 			"loggingImage", flatMap["loggingImage"],
@@ -301,7 +376,7 @@ func GenerateKustomizeResult(config opConfig.Config, kustomizeTemplate template.
 		)
 		_, err = paramsFile.WriteString(stringToWrite)
 		if err != nil {
-			return "", err
+			return "", nil, nil, err
 		}
 	}
 	//onepanel-config-map.env
@@ -309,18 +384,18 @@ func GenerateKustomizeResult(config opConfig.Config, kustomizeTemplate template.
 		//Clear previous env file
 		paramsPath := filepath.Join(localManifestsCopyPath, "vars", "onepanel-config-map.env")
 		if _, err := files.DeleteIfExists(paramsPath); err != nil {
-			return "", err
+			return "", nil, nil, err
 		}
 		paramsFile, err := os.Create(paramsPath)
 		if err != nil {
-			return "", err
+			return "", nil, nil, err
 		}
 		var stringToWrite = fmt.Sprintf("%v=%v\n",
 			"applicationDefaultNamespace", flatMap["applicationDefaultNamespace"],
 		)
 		_, err = paramsFile.WriteString(stringToWrite)
 		if err != nil {
-			return "", err
+			return "", nil, nil, err
 		}
 	} else {
 		log.Fatal("Missing required values in params.yaml, applicationDefaultNamespace")
@@ -339,7 +414,7 @@ func GenerateKustomizeResult(config opConfig.Config, kustomizeTemplate template.
 
 			err = replacePlaceholderForSecretManiFile(localManifestsCopyPath, artifactRepoSecretPlaceholder, artifactRepoS3Secret)
 			if err != nil {
-				return "", err
+				return "", nil, nil, err
 			}
 		} else {
 			log.Fatal("Missing required values in params.yaml, artifactRepository. Check accessKey, or secretKey.")
@@ -354,7 +429,7 @@ func GenerateKustomizeResult(config opConfig.Config, kustomizeTemplate template.
 			artifactRepoS3Secret := "artifactRepositoryGCSServiceAccountKey: '" + val.Value + "'"
 			err = replacePlaceholderForSecretManiFile(localManifestsCopyPath, artifactRepoSecretPlaceholder, artifactRepoS3Secret)
 			if err != nil {
-				return "", err
+				return "", nil, nil, err
 			}
 		} else {
 			log.Fatal("Missing required values in params.yaml, artifactRepository. artifactRepository.gcs.serviceAccountKey.")
@@ -365,18 +440,25 @@ func GenerateKustomizeResult(config opConfig.Config, kustomizeTemplate template.
 	//Find those files
 	listOfFiles, errorWalking := FilePathWalkDir(localManifestsCopyPath)
 	if errorWalking != nil {
-		return "", err
+		return "", nil, nil, err
 	}
 
+	// Replace the placeholders in the same order on every build.
+	flatMapKeys := make([]string, 0, len(flatMap))
+	for key := range flatMap {
+		flatMapKeys = append(flatMapKeys, key)
+	}
+	sort.Strings(flatMapKeys)
+
 	for _, filePath := range listOfFiles {
 		manifestFileContent, manifestFileOpenErr := ioutil.ReadFile(filePath)
 		if manifestFileOpenErr != nil {
-			return "", manifestFileOpenErr
+			return "", nil, nil, manifestFileOpenErr
 		}
 		manifestFileContentStr := string(manifestFileContent)
 		useStr := ""
 		rawStr := ""
-		for key := range flatMap {
+		for _, key := range flatMapKeys {
 			valueBool, okBool := flatMap[key].(bool)
 			if okBool {
 				useStr = strconv.FormatBool(valueBool)
@@ -412,18 +494,60 @@ func GenerateKustomizeResult(config opConfig.Config, kustomizeTemplate template.
 		}
 		writeFileErr := ioutil.WriteFile(filePath, []byte(manifestFileContentStr), 0644)
 		if writeFileErr != nil {
-			return "", writeFileErr
+			return "", nil, nil, writeFileErr
 		}
 	}
 
-	//Update the values in those files
-	rm, err := runKustomizeBuild(localManifestsCopyPath)
+	return localManifestsCopyPath, imageRegistry, secrets, nil
+}
+
+// addTLSSecretResource creates the istio gateway secret from the certificate and key set in
+// application.tls.cert and application.tls.key, if any, and adds it to the kustomize resources.
+// The secret is only added when the template includes istio, as its namespace has to exist.
+func addTLSSecretResource(yamlFile *util.DynamicYaml, secrets *util.Secrets, localManifestsCopyPath string, kustomizeTemplate *template.Kustomize) error {
+	if !yamlFile.HasKeys("application.tls.cert", "application.tls.key") {
+		return nil
+	}
+
+	includesIstio := false
+	for _, resource := range kustomizeTemplate.Resources {
+		if strings.Contains(resource, "istio") {
+			includesIstio = true
+			break
+		}
+	}
+	if !includesIstio {
+		return nil
+	}
+
+	// The values are only revealed in memory, the secret written to the cache holds a token instead of the key.
+	certificate, err := util.LoadTLSCertificate(secrets.Reveal(yamlFile.GetValue("application.tls.cert").Value), secrets.Reveal(yamlFile.GetValue("application.tls.key").Value))
 	if err != nil {
-		return "", err
+		return err
 	}
-	kustYaml, err := rm.AsYaml()
 
-	return string(kustYaml), nil
+	warnings, err := certificate.Verify(yamlFile.GetValue("application.fqdn").Value, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		log.Printf("[warning] %v", warning)
+	}
+
+	secretName := util.DefaultTLSSecretName
+	if yamlFile.HasKey("application.tls.secretName") {
+		secretName = yamlFile.GetValue("application.tls.secretName").Value
+	}
+
+	secretFileName := "tls-secret.yaml"
+	secretPath := filepath.Join(localManifestsCopyPath, secretFileName)
+	if err := ioutil.WriteFile(secretPath, []byte(certificate.SecretYaml(secretName, util.TLSSecretNamespace, secrets)), 0600); err != nil {
+		return err
+	}
+
+	kustomizeTemplate.Resources = append(kustomizeTemplate.Resources, secretFileName)
+
+	return nil
 }
 
 func replacePlaceholderForSecretManiFile(localManifestsCopyPath string, artifactRepoSecretPlaceholder string, artifactRepoSecretVal string) error {
@@ -507,6 +631,55 @@ func formatUrlForUi(url string) string {
 	return result
 }
 
+// loadImageRegistry returns the imageRegistry section of the params, with the prefix set by --image-registry.
+// The section is removed from yamlFile, as its keys are not manifest variables.
+func loadImageRegistry(yamlFile *util.DynamicYaml) (*util.ImageRegistry, error) {
+	imageRegistry, err := util.LoadImageRegistry(yamlFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if imageRegistry != nil {
+		if err := yamlFile.Delete("imageRegistry"); err != nil {
+			return nil, err
+		}
+	}
+
+	if ImageRegistryPrefix != "" {
+		if imageRegistry == nil {
+			imageRegistry = &util.ImageRegistry{}
+		}
+		imageRegistry.Prefix = ImageRegistryPrefix
+
+		if err := imageRegistry.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return imageRegistry, nil
+}
+
+// relocateImages replaces the container images in rm as set in imageRegistry and writes the mapping file.
+// apply builds twice, so the mapping is kept in relocatedImages to write the images of both builds.
+func relocateImages(rm resmap.ResMap, imageRegistry *util.ImageRegistry) error {
+	for _, res := range rm.Resources() {
+		object := res.Map()
+		imageRegistry.RelocateContainerImages(object, relocatedImages)
+		res.SetMap(object)
+	}
+
+	mappingFilePath := imageRegistry.MappingFile
+	if mappingFilePath == "" {
+		mappingFilePath = filepath.Join(currentStatePath(), util.DefaultImageMappingFileName)
+	}
+
+	if err := util.WriteImageMapping(mappingFilePath, relocatedImages); err != nil {
+		return fmt.Errorf("unable to write image mapping file %v: %v", mappingFilePath, err.Error())
+	}
+
+	return nil
+}
+
 func runKustomizeBuild(path string) (rm resmap.ResMap, err error) {
 	fSys := filesys.MakeFsOnDisk()
 	opts := &krusty.Options{
@@ -548,6 +721,49 @@ func generateApplicationNodePoolOptions(nodePoolData *yaml2.Node) string {
 	return nodePoolOptionsStr
 }
 
+// loadMetalLbSecretKey returns metalLb.secretKey from the params. If it is not set, the key in metalLbSecretKeyFilePath
+// is used, it is generated by the first build, so every build renders the same secret without writing to the params.
+// Each environment has its own key.
+func loadMetalLbSecretKey(params *util.DynamicYaml) (string, error) {
+	if params.HasKey("metalLb.secretKey") {
+		return params.GetValue("metalLb.secretKey").Value, nil
+	}
+
+	keyFilePath := metalLbSecretKeyFilePath()
+	exists, err := files.Exists(keyFilePath)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		data, err := ioutil.ReadFile(keyFilePath)
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	metalLbSecretKey, err := bcrypt.GenerateFromPassword([]byte(rand.String(128)), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	secretKey := base64.StdEncoding.EncodeToString(metalLbSecretKey)
+
+	if err := os.MkdirAll(filepath.Dir(keyFilePath), os.ModePerm); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(keyFilePath, []byte(secretKey+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("unable to save the metallb secret key in %v: %v", keyFilePath, err.Error())
+	}
+
+	return secretKey, nil
+}
+
+// metalLbSecretKeyFilePath returns the file with the generated metallb secret key of the environment set with --env.
+func metalLbSecretKeyFilePath() string {
+	return filepath.Join(currentStatePath(), "metallb-secret-key")
+}
+
 func generateMetalLbAddresses(nodePoolData []*yaml2.Node) string {
 	applicationNodePoolOptions := []string{""}
 	var appendStr string
@@ -565,28 +781,44 @@ func generateMetalLbAddresses(nodePoolData []*yaml2.Node) string {
 	return strings.Join(applicationNodePoolOptions, "")
 }
 
-// mapLinkedVars goes through the `default-vars.yaml` files which map variables from already existing variables
-// and set those variable values. If the value is already in the mapping, it is not mapped to the default.
+// mapLinkedVars goes through the `default-vars.yaml` files of the components, e.g. modeldb/base/default-vars.yaml,
+// which map variables from already existing variables and set those variable values.
+// If the value is already in the mapping, it is not mapped to the default.
 func mapLinkedVars(mapping map[string]interface{}, manifestPath string, config *opConfig.Config) error {
-	linkVars := false
 	for _, component := range config.Spec.Components {
-		if strings.Contains(component, "modeldb") {
-			linkVars = true
-			break
+		defaultVarsPath := filepath.Join(manifestPath, component, "default-vars.yaml")
+		exists, err := files.Exists(defaultVarsPath)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
 		}
-	}
 
-	if !linkVars {
-		return nil
+		if err := mapLinkedVarsFile(mapping, defaultVarsPath); err != nil {
+			return err
+		}
 	}
 
-	modelDBMapping, err := util.LoadDynamicYamlFromFile(filepath.Join(manifestPath, "modeldb", "base", "default-vars.yaml"))
+	return nil
+}
+
+// mapLinkedVarsFile sets the variables of the default-vars.yaml file at path that are not in mapping.
+func mapLinkedVarsFile(mapping map[string]interface{}, path string) error {
+	linkedVars, err := util.LoadDynamicYamlFromFile(path)
 	if err != nil {
 		return err
 	}
 
-	flatMappedVars := modelDBMapping.Flatten(util.LowerCamelCaseFlatMapKeyFormatter)
-	for key, valueNode := range flatMappedVars {
+	flatMappedVars := linkedVars.Flatten(util.LowerCamelCaseFlatMapKeyFormatter)
+	keys := make([]string, 0, len(flatMappedVars))
+	for key := range flatMappedVars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		valueNode := flatMappedVars[key]
 		// Skip if key already exists
 		if _, ok := mapping[key]; ok {
 			continue
@@ -595,7 +827,7 @@ func mapLinkedVars(mapping map[string]interface{}, manifestPath string, config *
 		valueKey := util.LowerCamelCaseStringFormat(valueNode.Value.Value, ".")
 		value, ok := mapping[valueKey]
 		if !ok {
-			return fmt.Errorf("unknown key %v", valueKey)
+			return fmt.Errorf("unknown key %v in %v", valueKey, path)
 		}
 
 		mapping[key] = value
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+
+	"github.com/onepanelio/cli/bundle"
+	opConfig "github.com/onepanelio/cli/config"
+	"github.com/onepanelio/cli/files"
+	"github.com/onepanelio/cli/manifest"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// BundleFile is the bundle init and apply install from instead of downloading the manifests.
+	BundleFile string
+	// BundleOutput is the file bundle create writes to.
+	BundleOutput string
+	// BundleImagesDirectory is a directory with image tarballs, named like opctl bundle create lists them, to add to the bundle.
+	BundleImagesDirectory string
+)
+
+var bundleCmd = &cobra.Command{
+	Use:     "bundle",
+	Short:   "Create bundles to install without network access.",
+	Example: "bundle create --output onepanel-bundle.tar.gz",
+	Run:     func(cmd *cobra.Command, args []string) {},
+}
+
+var bundleCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Packages the manifests, config, params and rendered YAML of config.yaml into a bundle.",
+	Long: "Packages the manifests, config, params and rendered YAML of config.yaml into a bundle, along with the list of " +
+		"every container image the YAML references. Use the bundle with opctl init --bundle and opctl apply --bundle " +
+		"on a machine without network access. The bundle contains the params, keep it as safe as params.yaml.",
+	Example: "bundle create --output onepanel-bundle.tar.gz --images-dir ./images",
+	Run: func(cmd *cobra.Command, args []string) {
+		configFilePath := currentConfigFilePath()
+
+		config, err := loadConfig(configFilePath)
+		if err != nil {
+			fmt.Printf("Unable to read configuration file: %v\n", err.Error())
+			return
+		}
+
+		log.Printf("Building...")
+		applicationYaml, kubernetesYaml, err := generateApplyYaml(config)
+		if err != nil {
+			log.Printf("Error generating result %v", err.Error())
+			return
+		}
+
+		options := &bundle.CreateOptions{
+			Config:          config,
+			ManifestsTag:    cachedManifestsTag(config.Spec.ManifestsRepo),
+			ApplicationYaml: applicationYaml,
+			KubernetesYaml:  kubernetesYaml,
+			ImagesDirectory: BundleImagesDirectory,
+			Output:          BundleOutput,
+		}
+
+		sourceConfigPath := sourceConfigFilePath()
+		exists, err := files.Exists(sourceConfigPath)
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+		if exists {
+			options.SourceConfigPath = sourceConfigPath
+		}
+
+		missingImages, err := bundle.Create(options)
+		if err != nil {
+			fmt.Printf("[error] Unable to create bundle: %v\n", err.Error())
+			return
+		}
+
+		if len(missingImages) > 0 {
+			fmt.Printf("%v has no tarball for these images, load them on the cluster nodes before applying:\n", BundleImagesDirectory)
+			for _, image := range missingImages {
+				fmt.Printf("- %v (%v)\n", image, bundle.ImageArchiveName(image))
+			}
+		}
+
+		fmt.Printf("Bundle has been created: %v\n", BundleOutput)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(bundleCmd)
+	bundleCmd.AddCommand(bundleCreateCmd)
+
+	bundleCreateCmd.Flags().StringVarP(&BundleOutput, "output", "o", "onepanel-bundle.tar.gz", "File path of the bundle")
+	bundleCreateCmd.Flags().StringVarP(&BundleImagesDirectory, "images-dir", "", "", "Directory with image tarballs to add, e.g. created with docker save. Tarballs are named after the image with /, : and @ replaced by _ and .tar appended")
+	bundleCreateCmd.Flags().BoolVarP(&Dev, "dev", "", false, "Sets conditions to allow development testing.")
+	bundleCreateCmd.Flags().StringVarP(&ImageRegistryPrefix, "image-registry", "", "", imageRegistryFlagUsage)
+	addParamOverrideFlags(bundleCreateCmd)
+}
+
+// bundleFilePath returns where init and apply extract the bundle passed with --bundle, in the state directory of the environment.
+func bundleFilePath() string {
+	return filepath.Join(currentStatePath(), "bundle")
+}
+
+// cachedManifestsTag returns the tag recorded in the manifests cache for manifestsRepo, if any.
+func cachedManifestsTag(manifestsRepo string) string {
+	entries, err := manifest.ListCache(manifestsFilePath())
+	if err != nil {
+		return ""
+	}
+
+	for _, entry := range entries {
+		if filepath.Clean(entry.Path) == filepath.Clean(manifestsRepo) {
+			return entry.Tag
+		}
+	}
+
+	return ""
+}
+
+// initFromBundle sets up cli_config.yaml, the config and the params from the bundle in BundleFile, without network access.
+// An existing params file is kept, so the params of a bundle can be changed before applying.
+func initFromBundle() error {
+	initBundle, err := bundle.Extract(BundleFile, bundleFilePath())
+	if err != nil {
+		return err
+	}
+
+	bundleConfig, err := initBundle.Config()
+	if err != nil {
+		return err
+	}
+
+	if err := manifest.CreateDirectorySourceConfigFile(filepath.Join(currentStatePath(), "cli_config.yaml"), initBundle.ManifestsPath()); err != nil {
+		return err
+	}
+
+	source, err := manifest.CreateDirectorySource(initBundle.ManifestsPath(), true)
+	if err != nil {
+		return err
+	}
+
+	if err := source.MoveToDirectory(manifestsFilePath()); err != nil {
+		return err
+	}
+
+	manifestsRepoPath, err := source.GetManifestPath()
+	if err != nil {
+		return err
+	}
+
+	if err := manifest.RecordCacheEntry(manifestsFilePath(), source); err != nil {
+		log.Printf("[warning] Unable to record manifests cache entry: %v", err.Error())
+	}
+
+	paramsExist, err := files.Exists(ParametersFilePath)
+	if err != nil {
+		return err
+	}
+	if !paramsExist {
+		if err := files.CopyFile(initBundle.ParamsPath(), ParametersFilePath); err != nil {
+			return err
+		}
+	}
+
+	bundleConfig.Spec.ManifestsRepo = manifestsRepoPath
+	bundleConfig.Spec.Params = opConfig.ParamsFiles{ParametersFilePath}
+
+	setupData, err := yaml.Marshal(bundleConfig)
+	if err != nil {
+		return fmt.Errorf("unable to marshal yaml data: %v", err.Error())
+	}
+
+	if err := ioutil.WriteFile(ConfigurationFilePath, setupData, 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("Configuration has been created from bundle %v\n", BundleFile)
+	if initBundle.Metadata.ManifestsTag != "" {
+		fmt.Printf("- Manifests: %v\n", initBundle.Metadata.ManifestsTag)
+	}
+	fmt.Printf("- Configuration file: %v\n", ConfigurationFilePath)
+	if paramsExist {
+		fmt.Printf("- Parameters file already exists and was kept: %v\n", ParametersFilePath)
+	} else {
+		fmt.Printf("- Parameters file has been copied from the bundle: %v\n", ParametersFilePath)
+	}
+
+	return nil
+}
@@ -0,0 +1,508 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	opConfig "github.com/onepanelio/cli/config"
+	"github.com/onepanelio/cli/manifest"
+	"github.com/onepanelio/cli/util"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// ComponentsExplain prints why each overlay was selected when adding components.
+var ComponentsExplain bool
+
+var componentsCmd = &cobra.Command{
+	Use:   "components",
+	Short: "Inspect and change the components of your configuration.",
+	Long: "Components are the directories of the manifests with a base directory, e.g. modeldb or common/istio. " +
+		"Overlays of a component adapt it, e.g. to a provider, and are selected by name, e.g. gcp.",
+	Example: "components list",
+	Run:     func(cmd *cobra.Command, args []string) {},
+}
+
+var componentsListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "Lists the components of the manifests, whether config.yaml enables them, and their overlays.",
+	Long:    "Lists the components of the manifests. The overlays config.yaml enables are marked with a *.",
+	Example: "components list",
+	Run: func(cmd *cobra.Command, args []string) {
+		config, loadedManifest, err := loadComponentsManifest()
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "NAME\tENABLED\tOVERLAYS")
+		for _, component := range loadedManifest.Components() {
+			enabled := "no"
+			if componentEnabled(config, component) {
+				enabled = "yes"
+			}
+
+			overlays := make([]string, 0)
+			for _, overlay := range component.Overlays() {
+				name := overlay.Name()
+				if overlayEnabled(config, overlay) {
+					name += "*"
+				}
+				overlays = append(overlays, name)
+			}
+
+			fmt.Fprintf(writer, "%v\t%v\t%v\n", component.Path(), enabled, strings.Join(overlays, ", "))
+		}
+		writer.Flush()
+	},
+}
+
+var componentsDescribeCmd = &cobra.Command{
+	Use:     "describe <name>",
+	Short:   "Shows the vars, dependencies and overlays of a component.",
+	Example: "components describe modeldb",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		config, loadedManifest, err := loadComponentsManifest()
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		component := loadedManifest.GetComponent(componentPathArg(args[0]))
+		if component == nil {
+			fmt.Printf("[error] unknown component '%v', opctl components list shows the available components\n", args[0])
+			return
+		}
+
+		if err := describeComponent(config, loadedManifest, component); err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+		}
+	},
+}
+
+var componentsAddCmd = &cobra.Command{
+	Use:   "add <name>...",
+	Short: "Adds components to config.yaml and their params to params.yaml.",
+	Long: "Adds components, along with the components they require, to config.yaml with the overlays matching the ones " +
+		"already enabled and the provider. The params of the new components that no params file sets are added to the last params file, " +
+		"existing values are kept.",
+	Example: "components add modeldb",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configFilePath := currentConfigFilePath()
+		config, err := loadConfig(configFilePath)
+		if err != nil {
+			fmt.Printf("Unable to read configuration file: %v\n", err.Error())
+			return
+		}
+
+		added, err := addComponents(config, args)
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+		if len(added) == 0 {
+			fmt.Printf("%v already enabled\n", strings.Join(args, ", "))
+			return
+		}
+
+		if err := writeConfig(configFilePath, config); err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		fmt.Printf("Added %v to %v, set their params in %v\n", strings.Join(added, ", "), configFilePath, config.Spec.Params.Last())
+	},
+}
+
+var componentsRemoveCmd = &cobra.Command{
+	Use:   "remove <name>...",
+	Short: "Removes components from config.yaml and their params from params.yaml.",
+	Long: "Removes components and their overlays from config.yaml. Components required by another enabled component " +
+		"can't be removed. The params only the removed components use are removed from the last params file.",
+	Example: "components remove modeldb",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configFilePath := currentConfigFilePath()
+		config, err := loadConfig(configFilePath)
+		if err != nil {
+			fmt.Printf("Unable to read configuration file: %v\n", err.Error())
+			return
+		}
+
+		if err := removeComponents(config, args); err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		if err := writeConfig(configFilePath, config); err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		fmt.Printf("Removed %v from %v\n", strings.Join(args, ", "), configFilePath)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(componentsCmd)
+	componentsCmd.AddCommand(componentsListCmd)
+	componentsCmd.AddCommand(componentsDescribeCmd)
+	componentsCmd.AddCommand(componentsAddCmd)
+	componentsCmd.AddCommand(componentsRemoveCmd)
+
+	componentsAddCmd.Flags().BoolVarP(&ComponentsExplain, "explain", "", false, "Print the overlay contender or config.yaml setting that selected each overlay")
+}
+
+// loadComponentsManifest loads config.yaml and the manifests it uses.
+func loadComponentsManifest() (*opConfig.Config, *manifest.Manifest, error) {
+	config, err := loadConfig(currentConfigFilePath())
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read configuration file: %v", err.Error())
+	}
+
+	loadedManifest, err := manifest.LoadManifest(config.Spec.ManifestsRepo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return config, loadedManifest, nil
+}
+
+// componentPathArg returns the path of a component passed as an argument, with or without /base.
+func componentPathArg(arg string) string {
+	return strings.TrimSuffix(filepath.Clean(arg), string(os.PathSeparator)+"base")
+}
+
+func componentEnabled(config *opConfig.Config, component *manifest.Component) bool {
+	for _, path := range config.Spec.Components {
+		if path == component.PathWithBase() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func overlayEnabled(config *opConfig.Config, overlay *manifest.Overlay) bool {
+	for _, path := range config.SelectedOverlays() {
+		if path == overlay.Path() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func describeComponent(config *opConfig.Config, loadedManifest *manifest.Manifest, component *manifest.Component) error {
+	metadata := component.Metadata()
+	orNone := func(values []string) string {
+		if len(values) == 0 {
+			return "-"
+		}
+		return strings.Join(values, ", ")
+	}
+
+	requiredBy := make([]string, 0)
+	for _, other := range loadedManifest.Components() {
+		for _, required := range other.Metadata().Requires {
+			if required == component.Path() {
+				requiredBy = append(requiredBy, other.Path())
+			}
+		}
+	}
+
+	providers := "all"
+	if len(metadata.Providers) != 0 {
+		providers = strings.Join(metadata.Providers, ", ")
+	}
+
+	enabled := "no"
+	if componentEnabled(config, component) {
+		enabled = "yes"
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "Name:\t%v\n", component.Path())
+	fmt.Fprintf(writer, "Enabled:\t%v\n", enabled)
+	fmt.Fprintf(writer, "Requires:\t%v\n", orNone(metadata.Requires))
+	fmt.Fprintf(writer, "Required by:\t%v\n", orNone(requiredBy))
+	fmt.Fprintf(writer, "Conflicts:\t%v\n", orNone(metadata.Conflicts))
+	fmt.Fprintf(writer, "Providers:\t%v\n", providers)
+	fmt.Fprintf(writer, "Requires overlays:\t%v\n", orNone(metadata.RequiresOverlays))
+	fmt.Fprintln(writer, "Overlays:")
+	for _, overlay := range component.Overlays() {
+		state := ""
+		if overlayEnabled(config, overlay) {
+			state = "enabled"
+		}
+		fmt.Fprintf(writer, "  %v\t%v\n", overlay.Name(), state)
+	}
+	writer.Flush()
+
+	vars, err := componentVars(loadedManifest, component)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Vars:")
+	for _, line := range strings.Split(strings.TrimSpace(vars), "\n") {
+		if line != "" {
+			fmt.Printf("  %v\n", line)
+		}
+	}
+
+	return nil
+}
+
+// componentVars returns the vars of the base of component, with their defaults, as yaml.
+func componentVars(loadedManifest *manifest.Manifest, component *manifest.Component) (string, error) {
+	builder := manifest.CreateBuilder(loadedManifest)
+	if err := builder.AddComponent(component.Path()); err != nil {
+		return "", err
+	}
+
+	vars := &util.DynamicYaml{}
+	vars.Merge(builder.GetComponentYamls(component.Path())...)
+	vars.Sort()
+
+	return vars.String()
+}
+
+// configBuilder returns a builder with the components and overlays of config, for the provider of the params.
+// The provider, https unless application.insecure is set, and the names of the enabled overlays are its overlay contenders.
+func configBuilder(config *opConfig.Config, loadedManifest *manifest.Manifest, params *util.DynamicYaml) (*manifest.Builder, error) {
+	builder := manifest.CreateBuilder(loadedManifest)
+
+	if params.HasKey("application.provider") {
+		provider := params.GetValue("application.provider").Value
+		builder.SetProvider(provider)
+		builder.AddOverlayContender(provider)
+	}
+	if params.HasKey("application.insecure") && params.GetValue("application.insecure").Value == "false" {
+		builder.AddOverlayContender("https")
+	}
+
+	for _, component := range config.Spec.Components {
+		if err := builder.AddComponent(componentPathArg(component)); err != nil {
+			return nil, err
+		}
+	}
+
+	builder.SetOverlaySelection(config.Spec.OverlaySelection)
+	for _, overlay := range config.SelectedOverlays() {
+		if err := builder.AddOverlay(overlay); err != nil {
+			return nil, err
+		}
+		builder.AddOverlayContender(filepath.Base(overlay))
+	}
+
+	return builder, nil
+}
+
+// printOverlayReasons prints why each overlay of builder was selected, for --explain.
+func printOverlayReasons(builder *manifest.Builder) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "OVERLAY\tREASON")
+	for _, reason := range builder.OverlayReasons() {
+		fmt.Fprintf(writer, "%v\t%v\n", reason.Overlay, reason.Reason)
+	}
+	writer.Flush()
+}
+
+// addComponents adds the components at paths to config, along with the ones they require, and merges their vars into
+// the last params file. It returns the paths of the components that were added.
+func addComponents(config *opConfig.Config, paths []string) ([]string, error) {
+	loadedManifest, err := manifest.LoadManifest(config.Spec.ManifestsRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := util.LoadParams(config)
+	if err != nil {
+		return nil, err
+	}
+
+	builder, err := configBuilder(config, loadedManifest, params)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		if err := builder.AddComponent(componentPathArg(path)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := builder.Build(); err != nil {
+		return nil, err
+	}
+	if ComponentsExplain {
+		printOverlayReasons(builder)
+	}
+
+	added := make([]string, 0)
+	for _, overlayComponent := range builder.GetOverlayComponents() {
+		component := overlayComponent.Component()
+		if componentEnabled(config, component) {
+			continue
+		}
+
+		added = append(added, component.Path())
+		config.AddComponent(component.PathWithBase())
+		for _, overlay := range overlayComponent.Overlays() {
+			config.AddOverlay(overlay.Path())
+		}
+	}
+	if len(added) == 0 {
+		return added, nil
+	}
+
+	// Only the vars that no params file sets are added, so the values of earlier files, e.g. shared base params, still win.
+	paramsFilePath := config.Spec.Params.Last()
+	paramsFile, err := util.LoadDynamicYamlFromFile(paramsFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	paramsFile.Merge(util.MissingParams(params, builder.GetComponentYamls(added...)...))
+	if err := paramsFile.WriteToFile(paramsFilePath); err != nil {
+		return nil, err
+	}
+
+	return added, nil
+}
+
+// removeComponents removes the components at paths and their overlays from config, and the params that only they use
+// from the last params file. Components that another remaining component requires are not removed.
+func removeComponents(config *opConfig.Config, paths []string) error {
+	loadedManifest, err := manifest.LoadManifest(config.Spec.ManifestsRepo)
+	if err != nil {
+		return err
+	}
+
+	removed := make(map[string]bool)
+	for _, path := range paths {
+		component := loadedManifest.GetComponent(componentPathArg(path))
+		if component == nil {
+			return fmt.Errorf("unknown component '%v'", path)
+		}
+		if !componentEnabled(config, component) {
+			return fmt.Errorf("component '%v' is not enabled", path)
+		}
+		removed[component.Path()] = true
+	}
+
+	remaining := make([]string, 0)
+	for _, path := range config.Spec.Components {
+		if !removed[componentPathArg(path)] {
+			remaining = append(remaining, componentPathArg(path))
+		}
+	}
+
+	for _, path := range remaining {
+		component := loadedManifest.GetComponent(path)
+		if component == nil {
+			continue
+		}
+		for _, required := range component.Metadata().Requires {
+			if removed[required] {
+				return fmt.Errorf("component '%v' is required by '%v', remove both to remove it", required, path)
+			}
+		}
+	}
+
+	removedPaths := make([]string, 0)
+	for path := range removed {
+		removedPaths = append(removedPaths, path)
+	}
+	sort.Strings(removedPaths)
+
+	removedKeys, err := componentsParamKeys(loadedManifest, removedPaths)
+	if err != nil {
+		return err
+	}
+	remainingKeys, err := componentsParamKeys(loadedManifest, remaining)
+	if err != nil {
+		return err
+	}
+
+	components := make([]string, 0)
+	for _, path := range config.Spec.Components {
+		if !removed[componentPathArg(path)] {
+			components = append(components, path)
+		}
+	}
+	overlays := make([]string, 0)
+	for _, path := range config.Spec.Overlays {
+		overlay := loadedManifest.GetOverlay(path)
+		if overlay != nil && removed[overlay.Component().Path()] {
+			continue
+		}
+		overlays = append(overlays, path)
+	}
+	config.Spec.Components = components
+	config.Spec.Overlays = overlays
+
+	paramsFilePath := config.Spec.Params.Last()
+	paramsFile, err := util.LoadDynamicYamlFromFile(paramsFilePath)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(removedKeys))
+	for key := range removedKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if remainingKeys[key] || !paramsFile.HasKey(key) {
+			continue
+		}
+		if err := paramsFile.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return paramsFile.WriteToFile(paramsFilePath)
+}
+
+// componentsParamKeys returns the top level keys of the vars of the components at paths, e.g. modeldb.
+func componentsParamKeys(loadedManifest *manifest.Manifest, paths []string) (map[string]bool, error) {
+	builder := manifest.CreateBuilder(loadedManifest)
+	keys := make(map[string]bool)
+	for _, path := range paths {
+		if loadedManifest.GetComponent(path) == nil {
+			continue
+		}
+		if err := builder.AddComponent(path); err != nil {
+			return nil, err
+		}
+
+		for _, vars := range builder.GetComponentYamls(path) {
+			for key := range vars.Flatten(util.AppendDotFlatMapKeyFormatter) {
+				keys[strings.Split(key, ".")[0]] = true
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+// writeConfig writes config to the config file at path.
+func writeConfig(path string, config *opConfig.Config) error {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("unable to marshal yaml data: %v", err.Error())
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
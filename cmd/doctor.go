@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+
+	opConfig "github.com/onepanelio/cli/config"
+	"github.com/onepanelio/cli/util"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:     "doctor",
+	Short:   "Checks that the cluster is ready for a deployment.",
+	Long:    "Checks the Kubernetes version, StorageClasses, permissions, LoadBalancers, GPU nodes and existing installs of the current cluster.",
+	Example: "doctor",
+	Run: func(cmd *cobra.Command, args []string) {
+		configFilePath := currentConfigFilePath()
+		config, err := loadConfig(configFilePath)
+		if err != nil {
+			fmt.Printf("Unable to read configuration file: %v\n", err.Error())
+			return
+		}
+
+		report, err := runPreflightChecks(config)
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		fmt.Print(report.String())
+		if report.HasFailures() {
+			fmt.Println("\nThe cluster is NOT ready for a deployment.")
+		} else {
+			fmt.Println("\nThe cluster is ready for a deployment.")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// runPreflightChecks checks the current cluster against the deployment described by config.
+func runPreflightChecks(config *opConfig.Config) (*util.PreflightReport, error) {
+	yamlFile, err := util.LoadParams(config)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := util.NewKubernetesClient()
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to cluster: %v", err.Error())
+	}
+
+	options := util.PreflightOptions{
+		Components: config.Spec.Components,
+	}
+	if yamlFile.HasKey("application.provider") {
+		options.Provider = yamlFile.GetValue("application.provider").Value
+	}
+
+	return util.RunPreflightChecks(client, options), nil
+}
@@ -0,0 +1,337 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	opConfig "github.com/onepanelio/cli/config"
+	"github.com/onepanelio/cli/files"
+	"github.com/onepanelio/cli/util"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// environmentsFilePath is the directory with one directory per environment, each with its config.yaml and params.yaml.
+const environmentsFilePath = "environments"
+
+var (
+	// Environment is the environment the commands use instead of config.yaml in the current directory.
+	Environment string
+	// EnvironmentFrom is the environment env create copies. The config.yaml in the current directory is used if empty.
+	EnvironmentFrom string
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Manage the environments of the workspace.",
+	Long: "Environments keep the config and params of several deployments, e.g. staging and prod, in one workspace. " +
+		"Each environment has a directory in " + environmentsFilePath + "/ with its config.yaml and params.yaml. " +
+		"The params.yaml of an environment is the last of its params files, so it overrides the shared params.yaml. Use an environment with --env.",
+	Example: "env create prod",
+	Run:     func(cmd *cobra.Command, args []string) {},
+}
+
+var envListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "Lists the environments.",
+	Example: "env list",
+	Run: func(cmd *cobra.Command, args []string) {
+		names, err := listEnvironments()
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "NAME\tPARAMS")
+		for _, name := range names {
+			config, err := loadEnvironmentConfig(name)
+			if err != nil {
+				fmt.Fprintf(writer, "%v\t%v\n", name, err.Error())
+				continue
+			}
+			fmt.Fprintf(writer, "%v\t%v\n", name, config.Spec.Params.String())
+		}
+		writer.Flush()
+	},
+}
+
+var envCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Creates an environment from config.yaml or another environment.",
+	Long: "Creates " + environmentsFilePath + "/<name> with a copy of config.yaml, or of the environment set with --from, " +
+		"and an empty params.yaml appended to its params files. Set the params that differ in the new params.yaml.",
+	Example: "env create prod --from staging",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if name == "" || filepath.Base(name) != name || name == "." || name == ".." {
+			fmt.Printf("[error] '%v' is not a valid environment name\n", name)
+			return
+		}
+
+		environmentPath := filepath.Join(environmentsFilePath, name)
+		exists, err := files.Exists(environmentPath)
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+		if exists {
+			fmt.Printf("[error] environment %v already exists\n", name)
+			return
+		}
+
+		config, err := loadEnvironmentConfig(EnvironmentFrom)
+		if err != nil {
+			fmt.Printf("Unable to read configuration file: %v\n", err.Error())
+			return
+		}
+
+		if err := os.MkdirAll(environmentPath, os.ModePerm); err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		// The params files of the copied config are kept, the new params.yaml comes last so it overrides them.
+		baseParams := config.Spec.Params
+		paramsPath := filepath.Join(environmentPath, "params.yaml")
+		params := []byte(fmt.Sprintf("# Params of the %v environment. Values set here override the ones in %v.\n", name, baseParams.String()))
+		config.Spec.Params = append(append(opConfig.ParamsFiles{}, baseParams...), paramsPath)
+		if err := ioutil.WriteFile(paramsPath, params, 0644); err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		configData, err := yaml.Marshal(config)
+		if err != nil {
+			fmt.Printf("[error] unable to marshal yaml data: %v\n", err.Error())
+			return
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(environmentPath, "config.yaml"), configData, 0644); err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		fmt.Printf("Environment %v has been created\n", name)
+		fmt.Printf("- Configuration file: %v\n", filepath.Join(environmentPath, "config.yaml"))
+		fmt.Printf("- Parameters file, overriding %v: %v\n", baseParams.String(), paramsPath)
+	},
+}
+
+var envDiffCmd = &cobra.Command{
+	Use:   "diff <from> [to]",
+	Short: "Shows the differences of the components, overlays and params of two environments.",
+	Long: "Shows the differences of the components, overlays and params of two environments. " +
+		"With one environment, it is compared to config.yaml in the current directory. The params are compared after merging.",
+	Example: "env diff staging prod",
+	Args:    cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		fromName, toName := "", args[0]
+		if len(args) == 2 {
+			fromName, toName = args[0], args[1]
+		}
+
+		fromConfig, fromParams, err := loadEnvironment(fromName)
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		toConfig, toParams, err := loadEnvironment(toName)
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		printListDifferences("Components", fromConfig.Spec.Components, toConfig.Spec.Components)
+		printListDifferences("Overlays", fromConfig.Spec.Overlays, toConfig.Spec.Overlays)
+
+		differences := util.DiffParams(fromParams, toParams)
+		if len(differences) == 0 {
+			return
+		}
+
+		fmt.Println("Params:")
+		for _, difference := range differences {
+			switch {
+			case difference.Added:
+				fmt.Printf("+ %v: %v\n", difference.Key, difference.To)
+			case difference.Removed:
+				fmt.Printf("- %v: %v\n", difference.Key, difference.From)
+			default:
+				fmt.Printf("~ %v: %v -> %v\n", difference.Key, difference.From, difference.To)
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&Environment, "env", "", "", "Environment to use, from "+environmentsFilePath+"/<env>/config.yaml. Defaults to config.yaml in the current directory")
+
+	rootCmd.AddCommand(envCmd)
+	envCmd.AddCommand(envListCmd)
+	envCmd.AddCommand(envCreateCmd)
+	envCmd.AddCommand(envDiffCmd)
+
+	envCreateCmd.Flags().StringVarP(&EnvironmentFrom, "from", "", "", "Environment to copy. Defaults to config.yaml in the current directory")
+}
+
+// environmentConfigFilePath returns the config file of name, or config.yaml in the current directory if name is empty.
+func environmentConfigFilePath(name string) string {
+	if name == "" {
+		return "config.yaml"
+	}
+
+	return filepath.Join(environmentsFilePath, name, "config.yaml")
+}
+
+// currentConfigFilePath returns the config file of the environment set with --env.
+func currentConfigFilePath() string {
+	return environmentConfigFilePath(Environment)
+}
+
+// loadConfig migrates the config file at path to the latest apiVersion, reads it and sets its param overrides, from the OPCTL_PARAM_ environment variables,
+// --set-file and --set, in that order.
+func loadConfig(path string) (*opConfig.Config, error) {
+	version, backupPath, err := opConfig.Migrate(path)
+	if err != nil {
+		return nil, err
+	}
+	if backupPath != "" {
+		log.Printf("%v has been migrated from %v to %v, the previous version is in %v", path, version, opConfig.APIVersion, backupPath)
+	}
+
+	config, err := opConfig.FromFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config.ParamOverrides, err = util.ParamOverrides(os.Environ(), ParamSetFiles, ParamSets)
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// environmentStatePath returns the directory with the downloaded manifests, the rendered YAML and the other files
+// the commands write for the environment name: .onepanel for the default environment, environments/<name>/.onepanel otherwise.
+func environmentStatePath(name string) string {
+	if name == "" {
+		return ".onepanel"
+	}
+
+	return filepath.Join(environmentsFilePath, name, ".onepanel")
+}
+
+// currentStatePath returns the state directory of the environment set with --env.
+func currentStatePath() string {
+	return environmentStatePath(Environment)
+}
+
+// manifestsFilePath returns the manifests cache of the environment set with --env.
+func manifestsFilePath() string {
+	return filepath.Join(currentStatePath(), "manifests")
+}
+
+// sourceConfigFilePath returns the cli_config.yaml of the environment set with --env.
+// An environment without one uses the cli_config.yaml of the default environment, until init copies it.
+func sourceConfigFilePath() string {
+	path := filepath.Join(currentStatePath(), "cli_config.yaml")
+	if exists, err := files.Exists(path); err == nil && !exists && Environment != "" {
+		return filepath.Join(environmentStatePath(""), "cli_config.yaml")
+	}
+
+	return path
+}
+
+// loadEnvironmentConfig loads the config of the environment name, or config.yaml in the current directory if name is empty.
+func loadEnvironmentConfig(name string) (*opConfig.Config, error) {
+	path := environmentConfigFilePath(name)
+	if name != "" {
+		exists, err := files.Exists(path)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("environment %v does not exist. Create it with opctl env create %v", name, name)
+		}
+	}
+
+	return loadConfig(path)
+}
+
+// loadEnvironment loads the config and the merged params of the environment name.
+func loadEnvironment(name string) (*opConfig.Config, *util.DynamicYaml, error) {
+	config, err := loadEnvironmentConfig(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	params, err := util.LoadParams(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return config, params, nil
+}
+
+// listEnvironments returns the names of the environments, sorted.
+func listEnvironments() ([]string, error) {
+	entries, err := ioutil.ReadDir(environmentsFilePath)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// printListDifferences prints the items that are only in from with a - and the items only in to with a +.
+func printListDifferences(title string, from, to []string) {
+	fromItems := make(map[string]bool)
+	for _, item := range from {
+		fromItems[item] = true
+	}
+	toItems := make(map[string]bool)
+	for _, item := range to {
+		toItems[item] = true
+	}
+
+	var lines []string
+	for _, item := range from {
+		if !toItems[item] {
+			lines = append(lines, "- "+item)
+		}
+	}
+	for _, item := range to {
+		if !fromItems[item] {
+			lines = append(lines, "+ "+item)
+		}
+	}
+
+	if len(lines) == 0 {
+		return
+	}
+
+	fmt.Printf("%v:\n", title)
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/onepanelio/cli/util"
+	"github.com/spf13/cobra"
+)
+
+// HostsFilePath is the hosts file updated by the hosts commands.
+var HostsFilePath string
+
+var hostsCmd = &cobra.Command{
+	Use:     "hosts",
+	Short:   "Manage hosts file entries for local deployments.",
+	Long:    "Manage the hosts file entries needed to reach minikube and microk8s deployments. Entries are kept in a block delimited by opctl comments.",
+	Example: "hosts sync",
+	Run:     func(cmd *cobra.Command, args []string) {},
+}
+
+var hostsSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Points the application and workspace hostnames to the ingress gateway in the hosts file.",
+	Long: "Writes a block to the hosts file that points application.fqdn and the hostnames of the workspaces to the ingress gateway IP. " +
+		"Running it again replaces the block. Writing to the system hosts file usually requires sudo.",
+	Example: "sudo opctl hosts sync",
+	Run: func(cmd *cobra.Command, args []string) {
+		configFilePath := currentConfigFilePath()
+		config, err := loadConfig(configFilePath)
+		if err != nil {
+			fmt.Printf("Unable to read configuration file: %v\n", err.Error())
+			return
+		}
+
+		yamlFile, err := util.LoadParams(config)
+		if err != nil {
+			fmt.Printf("Error parsing configuration file: %v\n", err.Error())
+			return
+		}
+
+		provider := ""
+		if yamlFile.HasKey("application.provider") {
+			provider = yamlFile.GetValue("application.provider").Value
+		}
+		if provider != "minikube" && provider != "microk8s" {
+			fmt.Printf("[error] hosts sync is only supported for minikube and microk8s, use DNS records for %v\n", provider)
+			return
+		}
+
+		ip, err := util.IngressGatewayAddress()
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+		if !util.IsIpv4(ip) {
+			fmt.Printf("[error] The ingress gateway does not have an external IP yet\n")
+			return
+		}
+
+		fqdn := yamlFile.GetValue("application.fqdn").Value
+		hostnames := []string{fqdn}
+
+		domain := strings.TrimPrefix(util.GetWildCardDNS(fqdn), "*.")
+		workspaceHosts, err := util.VirtualServiceHosts(domain)
+		if err != nil {
+			fmt.Printf("[warning] Unable to get workspace hostnames, only adding %v: %v\n", fqdn, err.Error())
+		}
+		hostnames = append(hostnames, workspaceHosts...)
+
+		changed, err := util.SyncHostsFile(HostsFilePath, ip, hostnames)
+		if err != nil {
+			fmt.Printf("[error] Unable to update %v: %v\n", HostsFilePath, err.Error())
+			return
+		}
+
+		if !changed {
+			fmt.Printf("%v is already up to date\n", HostsFilePath)
+			return
+		}
+
+		fmt.Printf("Pointed %v hostname(s) to %v in %v\n", len(hostnames), ip, HostsFilePath)
+	},
+}
+
+var hostsCleanCmd = &cobra.Command{
+	Use:     "clean",
+	Short:   "Removes the entries added by hosts sync from the hosts file.",
+	Example: "sudo opctl hosts clean",
+	Run: func(cmd *cobra.Command, args []string) {
+		changed, err := util.CleanHostsFile(HostsFilePath)
+		if err != nil {
+			fmt.Printf("[error] Unable to update %v: %v\n", HostsFilePath, err.Error())
+			return
+		}
+
+		if !changed {
+			fmt.Printf("%v has no opctl entries\n", HostsFilePath)
+			return
+		}
+
+		fmt.Printf("Removed opctl entries from %v\n", HostsFilePath)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(hostsCmd)
+	hostsCmd.AddCommand(hostsSyncCmd)
+	hostsCmd.AddCommand(hostsCleanCmd)
+
+	hostsCmd.PersistentFlags().StringVarP(&HostsFilePath, "hosts-file", "", util.DefaultHostsFilePath(), "Path of the hosts file to update")
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	opConfig "github.com/onepanelio/cli/config"
+	"github.com/onepanelio/cli/util"
+	"github.com/spf13/cobra"
+)
+
+// ImagesOutputFormat is the format images list prints in, text, json or csv.
+var ImagesOutputFormat string
+
+// imageUsage is an image and the components that use it.
+type imageUsage struct {
+	Image      string   `json:"image"`
+	Components []string `json:"components"`
+}
+
+var imagesCmd = &cobra.Command{
+	Use:     "images",
+	Short:   "Inspect the container images of your configuration.",
+	Example: "images list --output json",
+	Run:     func(cmd *cobra.Command, args []string) {},
+}
+
+var imagesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the container images config.yaml deploys, with the components that use them.",
+	Long: "Builds config.yaml like the build command and lists every container image in the result, including the images " +
+		"of custom resources like argo workflow templates. Images are relocated as set in imageRegistry of the params.",
+	Example: "images list --output csv",
+	Run: func(cmd *cobra.Command, args []string) {
+		if ImagesOutputFormat != "text" && ImagesOutputFormat != "json" && ImagesOutputFormat != "csv" {
+			fmt.Printf("'%v' is not a valid --output value. Valid values are: text, json, csv\n", ImagesOutputFormat)
+			return
+		}
+
+		configFilePath := currentConfigFilePath()
+
+		config, err := loadConfig(configFilePath)
+		if err != nil {
+			fmt.Printf("Unable to read configuration file: %v\n", err.Error())
+			return
+		}
+
+		log.Printf("Building...")
+		usages, err := listImages(config)
+		if err != nil {
+			log.Printf("Error generating result %v", err.Error())
+			return
+		}
+
+		if err := printImageUsages(usages, ImagesOutputFormat); err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(imagesCmd)
+	imagesCmd.AddCommand(imagesListCmd)
+
+	imagesListCmd.Flags().StringVarP(&ImagesOutputFormat, "output", "o", "text", "Output format. Valid values are: text, json, csv")
+	imagesListCmd.Flags().StringVarP(&ImageRegistryPrefix, "image-registry", "", "", imageRegistryFlagUsage)
+	imagesListCmd.Flags().BoolVarP(&Dev, "dev", "", false, "Sets conditions to allow development testing.")
+	addParamOverrideFlags(imagesListCmd)
+}
+
+// listImages builds each component of config on its own, to know which components use an image.
+// The manifests are only prepared once, for all components.
+func listImages(config *opConfig.Config) ([]*imageUsage, error) {
+	overlayComponents := config.GetOverlayComponents("")
+
+	kustomizeTemplate := TemplateFromSimpleOverlayedComponents(overlayComponents)
+	localManifestsCopyPath, imageRegistry, _, err := prepareManifests(*config, &kustomizeTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	components := make(map[string]map[string]bool)
+	for _, overlayComponent := range overlayComponents {
+		componentTemplate := TemplateFromSimpleOverlayedComponents([]*opConfig.SimpleOverlayedComponent{overlayComponent})
+		rm, err := buildManifests(localManifestsCopyPath, componentTemplate, imageRegistry)
+		if err != nil {
+			return nil, fmt.Errorf("unable to build %v: %v", overlayComponent.Name(), err.Error())
+		}
+
+		for _, res := range rm.Resources() {
+			for _, image := range util.ObjectContainerImages(res.Map()) {
+				if components[image] == nil {
+					components[image] = make(map[string]bool)
+				}
+				components[image][overlayComponent.Name()] = true
+			}
+		}
+	}
+
+	usages := make([]*imageUsage, 0)
+	for image, imageComponents := range components {
+		usage := &imageUsage{
+			Image:      image,
+			Components: make([]string, 0),
+		}
+		for component := range imageComponents {
+			usage.Components = append(usage.Components, component)
+		}
+		sort.Strings(usage.Components)
+
+		usages = append(usages, usage)
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		return usages[i].Image < usages[j].Image
+	})
+
+	return usages, nil
+}
+
+func printImageUsages(usages []*imageUsage, format string) error {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(usages, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "csv":
+		writer := csv.NewWriter(os.Stdout)
+		if err := writer.Write([]string{"image", "components"}); err != nil {
+			return err
+		}
+		for _, usage := range usages {
+			if err := writer.Write([]string{usage.Image, strings.Join(usage.Components, ";")}); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+
+		return writer.Error()
+	default:
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "IMAGE\tCOMPONENTS")
+		for _, usage := range usages {
+			fmt.Fprintf(writer, "%v\t%v\n", usage.Image, strings.Join(usage.Components, ", "))
+		}
+
+		return writer.Flush()
+	}
+
+	return nil
+}
@@ -18,7 +18,6 @@ import (
 )
 
 const (
-	manifestsFilePath             = ".onepanel/manifests"
 	artifactRepositoryProviderS3  = "s3"
 	artifactRepositoryProviderGcs = "gcs"
 )
@@ -36,6 +35,12 @@ var (
 	EnableMetalLb              bool
 	GPUDevicePlugins           []string
 	Services                   []string
+	TLSCertFile                string
+	TLSKeyFile                 string
+	// InitExplain prints the overlay contender that selected each overlay.
+	InitExplain bool
+	// InitUpdateManifestsTag sets the github source tag of cli_config.yaml to the tag this CLI was built for.
+	InitUpdateManifestsTag bool
 )
 
 type ProviderProperties struct {
@@ -65,6 +70,17 @@ var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Gets latest manifests and generates params.yaml file.",
 	Run: func(cmd *cobra.Command, args []string) {
+		if err := useEnvironmentFilePaths(cmd); err != nil {
+			log.Printf("[error] %v", err.Error())
+			return
+		}
+
+		if BundleFile != "" {
+			if err := initFromBundle(); err != nil {
+				log.Printf("[error] %v", err.Error())
+			}
+			return
+		}
 
 		if err := validateInput(); err != nil {
 			log.Println(err.Error())
@@ -72,18 +88,37 @@ var initCmd = &cobra.Command{
 		}
 
 		log.Printf("Initializing...")
-		configFile := filepath.Join(".onepanel/cli_config.yaml")
+		if err := os.MkdirAll(currentStatePath(), os.ModePerm); err != nil {
+			log.Printf("[error] %v", err.Error())
+			return
+		}
+
+		configFile := filepath.Join(currentStatePath(), "cli_config.yaml")
 		exists, err := files.Exists(configFile)
 		if err != nil {
 			log.Printf("[error] checking for config file %v", configFile)
 			return
 		}
 
+		// A new environment starts with the source of the default environment, so its settings, e.g. verification, are kept.
+		if defaultConfigFile := sourceConfigFilePath(); !exists && defaultConfigFile != configFile {
+			if err := files.CopyFile(defaultConfigFile, configFile); err != nil {
+				log.Printf("[error] copying %v: %v", defaultConfigFile, err.Error())
+				return
+			}
+			exists = true
+		}
+
 		if !exists {
 			if err := manifest.CreateGithubSourceConfigFile(configFile); err != nil {
 				log.Printf("[error] creating default source config: %v", err.Error())
 				return
 			}
+		} else if InitUpdateManifestsTag {
+			if err := manifest.UpdateGithubSourceTag(configFile, config.ManifestsRepositoryTag); err != nil {
+				log.Printf("[error] updating the manifests tag: %v", err.Error())
+				return
+			}
 		}
 
 		source, err := manifest.LoadManifestSourceFromFileConfig(configFile)
@@ -92,49 +127,45 @@ var initCmd = &cobra.Command{
 			return
 		}
 
-		// When updating cli versions, the cli_config.yaml may already exist.
-		// Check if we need to generate a new cli_config.yaml, to match the cli version.
-		tag := config.ManifestsRepositoryTag
-		if source.GetSourceType() == manifest.SourceGithub {
-			if source.GetTag() != "" {
-				if tag != source.GetTag() {
-					if err := manifest.CreateGithubSourceConfigFile(configFile); err != nil {
-						log.Printf("[error] creating default source config: %v", err.Error())
-						return
-					}
-					source, err = manifest.LoadManifestSourceFromFileConfig(configFile)
-					if err != nil {
-						log.Printf("[error] loading manifest source: %v", err.Error())
-						return
-					}
-				}
-			}
-		} else {
-			fmt.Printf("cli_config.yaml is using %v as source, ignoring CLI tag: %v", manifest.SourceDirectory, config.CLIVersion)
+		if source.GetSourceType() != manifest.SourceGithub {
+			fmt.Printf("cli_config.yaml is using %v as source, ignoring CLI tag: %v\n", source.GetSourceType(), config.CLIVersion)
 		}
 
-		if err := source.MoveToDirectory(filepath.Join(manifestsFilePath)); err != nil {
+		if err := source.MoveToDirectory(manifestsFilePath()); err != nil {
 			log.Printf("[error] %v", err.Error())
+			if _, ok := err.(*manifest.IncompatibleVersionError); ok {
+				log.Printf("The tag is manifestSource.github.tag in %v. Run opctl init --update-manifests-tag to set it to %v", configFile, config.ManifestsRepositoryTag)
+			}
 			return
 		}
 
+		// cli_config.yaml is kept as is when updating the CLI, the source checks that the manifests are supported.
+		// The tag is compared once it is resolved, so a constraint like ~0.13 is compared by the release it selected.
+		if source.GetSourceType() == manifest.SourceGithub && source.GetTag() != config.ManifestsRepositoryTag {
+			fmt.Printf("cli_config.yaml is using manifests %v, this CLI was built for %v\n", source.GetTag(), config.ManifestsRepositoryTag)
+		}
+
 		manifestsRepoPath, err := source.GetManifestPath()
 		if err != nil {
 			log.Printf("[error] %v", err.Error())
 			return
 		}
 
+		if err := manifest.RecordCacheEntry(manifestsFilePath(), source); err != nil {
+			log.Printf("[warning] Unable to record manifests cache entry: %v", err.Error())
+		}
+
 		if err := files.CreateIfNotExist(ParametersFilePath); err != nil {
 			log.Println(err.Error())
 		}
 
 		setup := config.Config{
-			ApiVersion: "opdef.apps.onepanel.io/v1alpha1",
-			Kind:       "OpDef",
+			ApiVersion: config.APIVersion,
+			Kind:       config.Kind,
 			Spec: config.ConfigSpec{
 				Components:    []string{},
 				ManifestsRepo: manifestsRepoPath,
-				Params:        ParametersFilePath,
+				Params:        config.ParamsFiles{ParametersFilePath},
 			},
 		}
 
@@ -192,6 +223,10 @@ var initCmd = &cobra.Command{
 			return
 		}
 
+		if InitExplain {
+			printOverlayReasons(bld)
+		}
+
 		for _, overlayComponent := range bld.GetOverlayComponents() {
 			setup.AddComponent(overlayComponent.Component().PathWithBase())
 			for _, overlay := range overlayComponent.Overlays() {
@@ -216,6 +251,13 @@ var initCmd = &cobra.Command{
 		mergedParams.Put("application.insecure", !EnableHTTPS)
 		mergedParams.Put("application.provider", Provider)
 
+		if TLSCertFile != "" {
+			if err := putTLSCertificateParams(mergedParams); err != nil {
+				log.Printf("[error] %v", err.Error())
+				return
+			}
+		}
+
 		removeUneededArtifactRepositoryProviders(mergedParams)
 
 		mergedParams.Sort()
@@ -277,15 +319,39 @@ func init() {
 	initCmd.Flags().BoolVarP(&EnableHTTPS, "enable-https", "", false, "Enable HTTPS scheme and redirect all requests to https://")
 	initCmd.Flags().BoolVarP(&EnableCertManager, "enable-cert-manager", "", false, "Automatically create/renew TLS certs using Let's Encrypt")
 	initCmd.Flags().BoolVarP(&EnableMetalLb, "enable-metallb", "", false, "Automatically create a LoadBalancer for non-cloud deployments.")
+	initCmd.Flags().BoolVarP(&InitExplain, "explain", "", false, "Print the overlay contender that selected each overlay")
+	initCmd.Flags().BoolVarP(&InitUpdateManifestsTag, "update-manifests-tag", "", false, "Set the manifests tag in cli_config.yaml to the one this CLI was built for, e.g. after updating the CLI")
 	initCmd.Flags().StringSliceVarP(&GPUDevicePlugins, "gpu-device-plugins", "", nil, "Install NVIDIA and/or AMD gpu device plugins. Valid values can be comma separated and are: amd, nvidia")
 	initCmd.Flags().StringSliceVarP(&Services, "services", "", nil, "Install additional services. Valid values can be comma separated and are: modeldb")
+	initCmd.Flags().StringVarP(&TLSCertFile, "tls-cert", "", "", "PEM encoded certificate to use for HTTPS instead of cert-manager. Must cover the wildcard domain.")
+	initCmd.Flags().StringVarP(&TLSKeyFile, "tls-key", "", "", "PEM encoded private key of the certificate set in --tls-cert")
+	initCmd.Flags().StringVarP(&BundleFile, "bundle", "", "", "Initialize from a bundle created with opctl bundle create, without network access. The other flags are ignored")
+}
+
+// useEnvironmentFilePaths points the config and params files into the directory of the environment set with --env,
+// unless they are set with --config and --params.
+func useEnvironmentFilePaths(cmd *cobra.Command) error {
+	if Environment == "" {
+		return nil
+	}
 
-	if err := initCmd.MarkFlagRequired("provider"); err != nil {
-		log.Printf("[error] %v", err)
+	environmentPath := filepath.Join(environmentsFilePath, Environment)
+	if !cmd.Flags().Changed("config") {
+		ConfigurationFilePath = filepath.Join(environmentPath, "config.yaml")
 	}
+	if !cmd.Flags().Changed("params") {
+		ParametersFilePath = filepath.Join(environmentPath, "params.yaml")
+	}
+
+	return os.MkdirAll(environmentPath, os.ModePerm)
 }
 
 func validateInput() error {
+	// provider can't be a required flag, as it is not needed with --bundle.
+	if Provider == "" {
+		return fmt.Errorf("provider flag is required")
+	}
+
 	if EnableCertManager && !EnableHTTPS {
 		return fmt.Errorf("enable-https flag is required when enable-cert-manager is set")
 	}
@@ -298,6 +364,18 @@ func validateInput() error {
 		return fmt.Errorf("enable-cert-manager flag is required when dns-provider is set")
 	}
 
+	if (TLSCertFile == "") != (TLSKeyFile == "") {
+		return fmt.Errorf("tls-cert and tls-key flags must be set together")
+	}
+
+	if TLSCertFile != "" && !EnableHTTPS {
+		return fmt.Errorf("enable-https flag is required when tls-cert is set")
+	}
+
+	if TLSCertFile != "" && EnableCertManager {
+		return fmt.Errorf("tls-cert can not be used with enable-cert-manager")
+	}
+
 	if err := validateProvider(Provider); err != nil {
 		return err
 	}
@@ -386,17 +464,20 @@ func validateServices(services []string) error {
 	return nil
 }
 
+// addCloudProviderToManifestBuilder adds the components of provider. The providers each component supports
+// are declared in its metadata, see manifest.ComponentMetadata.
 func addCloudProviderToManifestBuilder(provider string, builder *manifest.Builder) error {
+	builder.SetProvider(provider)
 	builder.AddOverlayContender(provider)
 
-	if (provider != "minikube" && provider != "microk8s") && EnableCertManager {
-		if err := builder.AddComponent("cert-manager"); err != nil {
+	if EnableCertManager {
+		if err := addProviderComponent(provider, builder, "cert-manager", "enable-cert-manager"); err != nil {
 			return err
 		}
 	}
 
-	if (provider == "minikube" || provider == "microk8s") && EnableMetalLb {
-		if err := builder.AddComponent("metallb"); err != nil {
+	if EnableMetalLb {
+		if err := addProviderComponent(provider, builder, "metallb", "enable-metallb"); err != nil {
 			return err
 		}
 	}
@@ -407,8 +488,20 @@ func addCloudProviderToManifestBuilder(provider string, builder *manifest.Builde
 	return nil
 }
 
+// addProviderComponent adds the component at path, enabled with flag. Components that don't support provider are skipped
+// with a warning, so init works for every provider. Components added with components add fail instead.
+func addProviderComponent(provider string, builder *manifest.Builder, path, flag string) error {
+	if !builder.SupportsProvider(path) {
+		log.Printf("[warning] %v does not support the %v provider, --%v is ignored", path, provider, flag)
+		return nil
+	}
+
+	return builder.AddComponent(path)
+}
+
+// addDNSProviderToManifestBuilder adds the cert-manager overlay of dns, unless cert-manager is skipped for the provider.
 func addDNSProviderToManifestBuilder(dns string, builder *manifest.Builder) error {
-	if dns == "" {
+	if dns == "" || !builder.SupportsProvider("cert-manager") {
 		return nil
 	}
 
@@ -418,6 +511,32 @@ func addDNSProviderToManifestBuilder(dns string, builder *manifest.Builder) erro
 	return builder.AddOverlay(overlay)
 }
 
+// putTLSCertificateParams checks the certificate and key passed in with --tls-cert and --tls-key
+// and stores their absolute paths in the params so build can create the gateway secret.
+func putTLSCertificateParams(mergedParams *util.DynamicYaml) error {
+	certPath, err := filepath.Abs(TLSCertFile)
+	if err != nil {
+		return err
+	}
+
+	keyPath, err := filepath.Abs(TLSKeyFile)
+	if err != nil {
+		return err
+	}
+
+	if _, err := util.LoadTLSCertificate(certPath, keyPath); err != nil {
+		return err
+	}
+
+	mergedParams.Put("application.tls.cert", certPath)
+	mergedParams.Put("application.tls.key", keyPath)
+	if !mergedParams.HasKey("application.tls.secretName") {
+		mergedParams.Put("application.tls.secretName", util.DefaultTLSSecretName)
+	}
+
+	return nil
+}
+
 func removeUneededArtifactRepositoryProviders(mergedParams *util.DynamicYaml) {
 	artifactRepoProviders := []string{artifactRepositoryProviderS3, artifactRepositoryProviderGcs}
 	var nodeKeyStr string
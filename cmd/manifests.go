@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	opConfig "github.com/onepanelio/cli/config"
+	"github.com/onepanelio/cli/files"
+	"github.com/onepanelio/cli/github"
+	"github.com/onepanelio/cli/manifest"
+	"github.com/onepanelio/cli/oci"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// ManifestsPrereleases includes prereleases in manifests releases.
+	ManifestsPrereleases bool
+	// ManifestsCacheKeep is the number of cache entries manifests cache prune keeps.
+	ManifestsCacheKeep int
+	// ManifestsCacheDryRun makes manifests cache prune only print what would be deleted.
+	ManifestsCacheDryRun bool
+	// ManifestsInsecureRegistry makes manifests push use plain http.
+	ManifestsInsecureRegistry bool
+	// ManifestsSignatureFile is a detached signature of the pushed archive, added to the artifact.
+	ManifestsSignatureFile string
+)
+
+var manifestsCmd = &cobra.Command{
+	Use:     "manifests",
+	Short:   "Manage manifest releases, the local manifests cache and manifest sources.",
+	Example: "manifests cache ls",
+	Run:     func(cmd *cobra.Command, args []string) {},
+}
+
+var manifestsReleasesCmd = &cobra.Command{
+	Use:     "releases",
+	Short:   "Lists the releases of the manifests repository.",
+	Example: "manifests releases --prerelease",
+	Run: func(cmd *cobra.Command, args []string) {
+		githubApi, err := manifestsGithubApi()
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		releases, err := githubApi.ListReleases(ManifestsPrereleases)
+		if err != nil {
+			fmt.Printf("[error] Unable to list releases: %v\n", err.Error())
+			return
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "TAG\tPUBLISHED\tPRERELEASE")
+		for _, release := range releases {
+			fmt.Fprintf(writer, "%v\t%v\t%v\n", release.TagName, release.CreatedAt, release.Prerelease)
+		}
+		writer.Flush()
+	},
+}
+
+var manifestsCacheCmd = &cobra.Command{
+	Use:     "cache",
+	Short:   "Manage the downloaded manifests in .onepanel/manifests, or in the .onepanel directory of the environment.",
+	Example: "manifests cache prune --keep 2",
+	Run:     func(cmd *cobra.Command, args []string) {},
+}
+
+var manifestsCacheLsCmd = &cobra.Command{
+	Use:     "ls",
+	Short:   "Lists the cached manifests, most recently used first.",
+	Example: "manifests cache ls",
+	Run: func(cmd *cobra.Command, args []string) {
+		entries, err := manifest.ListCache(manifestsFilePath())
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		inUse := manifestsRepoInUse()
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "NAME\tTAG\tSOURCE\tSIZE\tAGE\tIN USE")
+		for _, entry := range entries {
+			used := ""
+			if inUse != "" && filepath.Clean(inUse) == filepath.Clean(entry.Path) {
+				used = "*"
+			}
+			fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\n", entry.Name, entry.Tag, entry.Source, formatSize(entry.Size), formatAge(time.Since(entry.DownloadedAt)), used)
+		}
+		writer.Flush()
+	},
+}
+
+var manifestsCachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Deletes all but the most recently used cached manifests.",
+	Long: "Deletes all but the --keep most recently used cached manifests. " +
+		"The manifests used by config.yaml or by an environment are never deleted and don't count towards --keep.",
+	Example: "manifests cache prune --keep 2",
+	Run: func(cmd *cobra.Command, args []string) {
+		protectedPaths, err := manifestsReposInUse()
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		pruned, err := manifest.PruneCache(manifestsFilePath(), ManifestsCacheKeep, protectedPaths, ManifestsCacheDryRun)
+		for _, entry := range pruned {
+			if ManifestsCacheDryRun {
+				fmt.Printf("Would delete %v (%v)\n", entry.Name, formatSize(entry.Size))
+			} else {
+				fmt.Printf("Deleted %v (%v)\n", entry.Name, formatSize(entry.Size))
+			}
+		}
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		if len(pruned) == 0 {
+			fmt.Printf("Nothing to prune\n")
+		}
+	},
+}
+
+// manifestsGithubApi returns the client of the github source in cli_config.yaml, so its repository, API url
+// and token are used. Without a github source, the client for onepanelio/manifests on github.com is returned.
+func manifestsGithubApi() (*github.Github, error) {
+	source, err := manifest.LoadManifestSourceFromFileConfig(sourceConfigFilePath())
+	if err == nil {
+		if githubSource, ok := source.(*manifest.GithubSource); ok {
+			return githubSource.GithubApi()
+		}
+	}
+
+	return github.New(github.RepositoryUrl(github.DefaultApiUrl, manifest.DefaultManifestsRepository))
+}
+
+// manifestsRepoInUse returns the manifestsRepo of config.yaml, or an empty string if there is no config.yaml.
+func manifestsRepoInUse() string {
+	config, err := opConfig.FromFile(currentConfigFilePath())
+	if err != nil {
+		return ""
+	}
+
+	return config.Spec.ManifestsRepo
+}
+
+// manifestsReposInUse returns the manifestsRepo of config.yaml and of every environment.
+// Environments created with env create keep using the manifests of the config they were copied from.
+func manifestsReposInUse() ([]string, error) {
+	names, err := listEnvironments()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, name := range append([]string{""}, names...) {
+		config, err := opConfig.FromFile(environmentConfigFilePath(name))
+		if err != nil {
+			continue
+		}
+
+		if config.Spec.ManifestsRepo != "" {
+			paths = append(paths, config.Spec.ManifestsRepo)
+		}
+	}
+
+	return paths, nil
+}
+
+// formatSize returns size in a human readable unit, e.g. 1.5 MiB.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%v B", size)
+	}
+
+	divisor, exponent := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		divisor *= unit
+		exponent++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(divisor), "KMGTPE"[exponent])
+}
+
+// formatAge returns duration rounded to the largest unit, e.g. 3d.
+func formatAge(duration time.Duration) string {
+	switch {
+	case duration >= 24*time.Hour:
+		return fmt.Sprintf("%vd", int(duration.Hours()/24))
+	case duration >= time.Hour:
+		return fmt.Sprintf("%vh", int(duration.Hours()))
+	case duration >= time.Minute:
+		return fmt.Sprintf("%vm", int(duration.Minutes()))
+	}
+
+	return fmt.Sprintf("%vs", int(duration.Seconds()))
+}
+
+var manifestsPushCmd = &cobra.Command{
+	Use:   "push <directory|archive.tar.gz> <reference>",
+	Short: "Publishes a manifests directory to an OCI registry.",
+	Long: "Publishes a manifests directory or .tar.gz archive as an OCI artifact that can be used with the oci manifest source. " +
+		"To sign the artifact, archive the directory, sign the archive with minisign or cosign and push the archive with --signature. " +
+		"Registry credentials are read from " + manifest.OCIUsernameEnv + " and " + manifest.OCIPasswordEnv + ".",
+	Example: "manifests push manifests.tar.gz oci://registry.example.com/onepanel/manifests:v0.10.0 --signature manifests.tar.gz.minisig",
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ref, err := oci.ParseReference(args[1])
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		info, err := os.Stat(args[0])
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		archivePath := args[0]
+		if info.IsDir() {
+			tempPath, err := ioutil.TempDir("", "opctl-manifests-push")
+			if err != nil {
+				fmt.Printf("[error] %v\n", err.Error())
+				return
+			}
+			defer os.RemoveAll(tempPath)
+
+			archivePath = filepath.Join(tempPath, "manifests.tar.gz")
+			if err := files.Tar(args[0], archivePath); err != nil {
+				fmt.Printf("[error] Unable to archive %v: %v\n", args[0], err.Error())
+				return
+			}
+		} else if !strings.HasSuffix(archivePath, ".tar.gz") && !strings.HasSuffix(archivePath, ".tgz") {
+			fmt.Printf("[error] %v must be a directory or a .tar.gz archive\n", archivePath)
+			return
+		}
+
+		var annotations map[string]string
+		if ManifestsSignatureFile != "" {
+			signature, err := ioutil.ReadFile(ManifestsSignatureFile)
+			if err != nil {
+				fmt.Printf("[error] Unable to read signature: %v\n", err.Error())
+				return
+			}
+			annotations = map[string]string{oci.SignatureAnnotation: base64.StdEncoding.EncodeToString(signature)}
+		}
+
+		client := oci.New(os.Getenv(manifest.OCIUsernameEnv), os.Getenv(manifest.OCIPasswordEnv), ManifestsInsecureRegistry)
+		digest, err := client.PushManifests(ref, archivePath, annotations)
+		if err != nil {
+			fmt.Printf("[error] Unable to push %v: %v\n", ref, err.Error())
+			return
+		}
+
+		fmt.Printf("Pushed %v\nDigest: %v\n", ref, digest)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(manifestsCmd)
+	manifestsCmd.AddCommand(manifestsReleasesCmd)
+	manifestsCmd.AddCommand(manifestsCacheCmd)
+	manifestsCacheCmd.AddCommand(manifestsCacheLsCmd)
+	manifestsCacheCmd.AddCommand(manifestsCachePruneCmd)
+	manifestsCmd.AddCommand(manifestsPushCmd)
+
+	manifestsReleasesCmd.Flags().BoolVarP(&ManifestsPrereleases, "prerelease", "", false, "Include prereleases")
+	manifestsCachePruneCmd.Flags().IntVarP(&ManifestsCacheKeep, "keep", "", 1, "Number of most recently used entries to keep")
+	manifestsCachePruneCmd.Flags().BoolVarP(&ManifestsCacheDryRun, "dry-run", "", false, "Only print the entries that would be deleted")
+
+	manifestsPushCmd.Flags().BoolVarP(&ManifestsInsecureRegistry, "insecure", "", false, "Use plain http to reach the registry")
+	manifestsPushCmd.Flags().StringVarP(&ManifestsSignatureFile, "signature", "", "", "Detached minisign or cosign signature of the archive to attach to the artifact")
+}
@@ -0,0 +1,317 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/onepanelio/cli/util"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	DiscoverNodesLabel  string
+	DiscoverNodesDryRun bool
+	// RenderShowOrigins comments each value printed by params render with the file it came from.
+	RenderShowOrigins bool
+	// ParamSets are the key=value params set with --set.
+	ParamSets []string
+	// ParamSetFiles are the key=path params set to the content of a file with --set-file.
+	ParamSetFiles []string
+)
+
+var paramsCmd = &cobra.Command{
+	Use:     "params",
+	Short:   "Various params.yaml functions.",
+	Long:    "Inspect and update the parameters file.",
+	Example: "params render",
+	Run:     func(cmd *cobra.Command, args []string) {},
+}
+
+var discoverNodesCmd = &cobra.Command{
+	Use:   "discover-nodes",
+	Short: "Fills application.nodePool.options from the nodes of the cluster.",
+	Long: "Lists the nodes of the cluster, groups them by instance type and writes one application.nodePool.options entry per group, " +
+		"with the CPU, memory and GPU limits of the smallest node in the group.",
+	Example: "params discover-nodes --dry-run",
+	Run: func(cmd *cobra.Command, args []string) {
+		configFilePath := currentConfigFilePath()
+		config, err := loadConfig(configFilePath)
+		if err != nil {
+			fmt.Printf("Unable to read configuration file: %v\n", err.Error())
+			return
+		}
+
+		// The node pool is read from the merged params, but only written to the file that sets it, or the last params file.
+		params, origins, err := util.LoadParamsWithOrigins(config)
+		if err != nil {
+			fmt.Printf("Error parsing configuration file: %v\n", err.Error())
+			return
+		}
+
+		paramsFilePath := config.Spec.Params.Last()
+		for _, path := range config.Spec.Params {
+			if origins["application.nodePool.label"] == path {
+				paramsFilePath = path
+			}
+		}
+
+		yamlFile, err := util.LoadDynamicYamlFromFile(paramsFilePath)
+		if err != nil {
+			fmt.Printf("Error parsing configuration file: %v\n", err.Error())
+			return
+		}
+
+		client, err := util.NewKubernetesClient()
+		if err != nil {
+			fmt.Printf("[error] Unable to connect to cluster: %v\n", err.Error())
+			return
+		}
+
+		nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{})
+		if err != nil {
+			fmt.Printf("[error] Unable to list nodes: %v\n", err.Error())
+			return
+		}
+
+		label := DiscoverNodesLabel
+		if label == "" && params.HasKey("application.nodePool.label") {
+			label = params.GetValue("application.nodePool.label").Value
+		}
+		if label == "" && len(nodes.Items) != 0 {
+			label = util.InstanceTypeLabelBeta
+			if _, ok := nodes.Items[0].Labels[util.InstanceTypeLabel]; ok {
+				label = util.InstanceTypeLabel
+			}
+		}
+
+		options := util.NodePoolOptionsFromNodes(nodes.Items, label)
+		if len(options) == 0 {
+			fmt.Printf("[error] None of the %v nodes has the %v label\n", len(nodes.Items), label)
+			return
+		}
+
+		optionsNode := &yaml.Node{}
+		if err := optionsNode.Encode(options); err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		yamlFile.Put("application.nodePool.label", label)
+		if _, err := yamlFile.PutNode("application.nodePool.options", optionsNode); err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		if DiscoverNodesDryRun {
+			nodePool := util.DynamicYaml{}
+			if _, err := nodePool.PutNode("application.nodePool", yamlFile.GetValue("application.nodePool")); err != nil {
+				fmt.Printf("[error] %v\n", err.Error())
+				return
+			}
+
+			result, err := nodePool.String()
+			if err != nil {
+				fmt.Printf("[error] %v\n", err.Error())
+				return
+			}
+
+			fmt.Print(result)
+			return
+		}
+
+		if err := yamlFile.WriteToFile(paramsFilePath); err != nil {
+			fmt.Printf("[error] Unable to write %v: %v\n", paramsFilePath, err.Error())
+			return
+		}
+
+		fmt.Printf("Added %v node pool option(s) to %v\n", len(options), paramsFilePath)
+	},
+}
+
+var renderParamsCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Prints the params after merging the params files.",
+	Long: "Merges the params files of the config, in order, sets the overrides and prints the result. Mappings are merged key by key, " +
+		"any other value, lists included, is replaced by the one in a later file. Each value is commented with the file or override it came from.",
+	Example: "params render --show-origins=false",
+	Run: func(cmd *cobra.Command, args []string) {
+		configFilePath := currentConfigFilePath()
+		config, err := loadConfig(configFilePath)
+		if err != nil {
+			fmt.Printf("Unable to read configuration file: %v\n", err.Error())
+			return
+		}
+
+		params, origins, err := util.LoadParamsWithOrigins(config)
+		if err != nil {
+			fmt.Printf("Error parsing configuration file: %v\n", err.Error())
+			return
+		}
+
+		if RenderShowOrigins {
+			util.CommentOrigins(params, origins)
+		}
+
+		result, err := params.String()
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		fmt.Print(result)
+	},
+}
+
+var editParamsCmd = &cobra.Command{
+	Use:   "edit [file]",
+	Short: "Edits an encrypted params file.",
+	Long: "Decrypts a params file, the last params file of the config by default, into $EDITOR and encrypts it again " +
+		"with the same keys once the editor is closed. The decrypted file is only readable by the current user and removed afterwards. " +
+		"Invalid yaml is opened again in the editor, or kept in the decrypted file if you quit.",
+	Example: "params edit params.yaml",
+	Args:    cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		paramsFilePath, err := paramsFileArg(args)
+		if err != nil {
+			fmt.Printf("Unable to read configuration file: %v\n", err.Error())
+			return
+		}
+
+		yamlFile, err := util.LoadDynamicYamlFromFile(paramsFilePath)
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+		if yamlFile.Encryption() == nil {
+			fmt.Printf("[error] %v is not encrypted, encrypt it with sops --encrypt --in-place %v\n", paramsFilePath, paramsFilePath)
+			return
+		}
+
+		decrypted, err := yamlFile.String()
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+
+		editedYaml, err := editParams(decrypted)
+		if err != nil {
+			fmt.Printf("[error] %v\n", err.Error())
+			return
+		}
+		if editedYaml == nil {
+			fmt.Printf("%v has not been changed\n", paramsFilePath)
+			return
+		}
+
+		editedYaml.SetEncryption(yamlFile.Encryption())
+		if err := editedYaml.WriteToFile(paramsFilePath); err != nil {
+			fmt.Printf("[error] Unable to write %v: %v\n", paramsFilePath, err.Error())
+			return
+		}
+
+		fmt.Printf("%v has been encrypted\n", paramsFilePath)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(paramsCmd)
+	paramsCmd.AddCommand(discoverNodesCmd)
+	paramsCmd.AddCommand(renderParamsCmd)
+	paramsCmd.AddCommand(editParamsCmd)
+
+	discoverNodesCmd.Flags().StringVarP(&DiscoverNodesLabel, "label", "l", "", "Node label to group nodes by. Defaults to application.nodePool.label or the instance-type label")
+	discoverNodesCmd.Flags().BoolVarP(&DiscoverNodesDryRun, "dry-run", "", false, "Print the node pool instead of writing it to the params file")
+	renderParamsCmd.Flags().BoolVarP(&RenderShowOrigins, "show-origins", "", true, "Comment each value with the params file it came from")
+	addParamOverrideFlags(renderParamsCmd)
+}
+
+// addParamOverrideFlags adds --set and --set-file to cmd. Only the commands that render the params have them.
+func addParamOverrideFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVarP(&ParamSets, "set", "", nil, "Set a param after the params files are merged, e.g. --set application.fqdn=example.com. Can be repeated")
+	cmd.Flags().StringArrayVarP(&ParamSetFiles, "set-file", "", nil, "Set a param to the content of a file, e.g. --set-file application.tls.cert=tls.crt. Can be repeated")
+}
+
+// paramsFileArg returns the params file in args, or the last params file of the config.
+func paramsFileArg(args []string) (string, error) {
+	if len(args) == 1 {
+		return args[0], nil
+	}
+
+	config, err := loadConfig(currentConfigFilePath())
+	if err != nil {
+		return "", err
+	}
+
+	return config.Spec.Params.Last(), nil
+}
+
+// editParams opens content in $VISUAL or $EDITOR, vi by default, until the changes are valid yaml, and returns them.
+// It returns nil if content was not changed. If the user quits with invalid changes, the file is kept, so they are not lost.
+func editParams(content string) (*util.DynamicYaml, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// TempFile creates the file only readable by the current user.
+	file, err := ioutil.TempFile("", "opctl-params-*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	keepFile := false
+	defer func() {
+		if !keepFile {
+			os.Remove(file.Name())
+		}
+	}()
+
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+
+	input := bufio.NewReader(os.Stdin)
+	for {
+		// The editor may have arguments, e.g. code --wait.
+		editorCmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", file.Name())
+		editorCmd.Stdin = os.Stdin
+		editorCmd.Stdout = os.Stdout
+		editorCmd.Stderr = os.Stderr
+		if err := editorCmd.Run(); err != nil {
+			return nil, fmt.Errorf("%v failed: %v", editor, err.Error())
+		}
+
+		edited, err := ioutil.ReadFile(file.Name())
+		if err != nil {
+			return nil, err
+		}
+		if string(edited) == content {
+			return nil, nil
+		}
+
+		editedYaml, err := util.LoadDynamicYamlFromString(string(edited))
+		if err == nil {
+			return editedYaml, nil
+		}
+
+		fmt.Printf("[error] The changes are not valid yaml: %v\n", err.Error())
+		fmt.Print("Press enter to edit them again, or enter q to quit: ")
+		answer, err := input.ReadString('\n')
+		if err != nil || strings.TrimSpace(answer) == "q" {
+			keepFile = true
+			return nil, fmt.Errorf("the changes have not been saved. They are kept decrypted in %v, delete it once you are done with them", file.Name())
+		}
+	}
+}
@@ -3,7 +3,9 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
+	"github.com/onepanelio/cli/files"
 	"github.com/spf13/cobra"
 
 	homedir "github.com/mitchellh/go-homedir"
@@ -72,6 +74,14 @@ func initConfig() {
 	}
 }
 
+// createHiddenFolder creates the state directory of the environment. An environment that doesn't exist is left
+// to init and env create, so a mistyped --env doesn't create it.
 func createHiddenFolder() {
-	os.MkdirAll(".onepanel", os.ModePerm)
+	if Environment != "" {
+		if exists, err := files.Exists(filepath.Join(environmentsFilePath, Environment)); err != nil || !exists {
+			return
+		}
+	}
+
+	os.MkdirAll(currentStatePath(), os.ModePerm)
 }
@@ -1,17 +1,21 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
-	"github.com/ghodss/yaml"
 	"github.com/onepanelio/cli/files"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
 var (
 	CLIVersion             string
 	ManifestsRepositoryTag string
+	// ManifestsCompatibility is the semver range of manifests the CLI supports, e.g. ">=0.12 <0.14".
+	// If empty, the patch releases of ManifestsRepositoryTag are supported.
+	ManifestsCompatibility string
 	CoreImageTag           string
 	CoreUIImageTag         string
 )
@@ -36,6 +40,15 @@ func (s *SimpleOverlayedComponent) AddPart(name *string) {
 	s.parts = append(s.parts, name)
 }
 
+// Name returns the path of the component without /base, e.g. istio.
+func (s *SimpleOverlayedComponent) Name() string {
+	if len(s.parts) == 0 {
+		return ""
+	}
+
+	return strings.TrimSuffix(*s.parts[0], string(os.PathSeparator)+"base")
+}
+
 // If there is one part, return just that part.
 // If there is more than one, return all but the first.
 func (s *SimpleOverlayedComponent) PartsSkipFirst() []*string {
@@ -46,17 +59,135 @@ func (s *SimpleOverlayedComponent) PartsSkipFirst() []*string {
 	return s.parts[1:]
 }
 
+// Config is config.yaml with apiVersion APIVersion. FromFile converts older versions to it.
 type Config struct {
 	ApiVersion string     `yaml:"apiVersion"`
 	Kind       string     `yaml:"kind"`
 	Spec       ConfigSpec `yaml:"spec"`
+	// ParamOverrides are set after the params files are merged, e.g. from --set. They are never written to config.yaml.
+	ParamOverrides []ParamOverride `yaml:"-" json:"-"`
+}
+
+// ParamOverride sets a param after the params files are merged.
+type ParamOverride struct {
+	Key        string // dotted path, e.g. application.fqdn
+	Value      string
+	Source     string // where the override comes from, e.g. --set
+	IgnoreCase bool   // the parts of Key match the existing keys regardless of case, e.g. for environment variables
+	String     bool   // the value is always a string, instead of being read like a yaml scalar
 }
 
 type ConfigSpec struct {
-	ManifestsRepo string   `yaml:"manifestsRepo"`
-	Params        string   `yaml:"params"`
-	Components    []string `yaml:"components"`
-	Overlays      []string `yaml:"overlays"`
+	ManifestsRepo string      `yaml:"manifestsRepo"`
+	Params        ParamsFiles `yaml:"params"`
+	Components    []string    `yaml:"components"`
+	Overlays      []string    `yaml:"overlays"`
+	// OverlaySelection pins and excludes overlays, keyed by component path, e.g. common/istio.
+	OverlaySelection map[string]OverlaySelection `yaml:"overlaySelection,omitempty"`
+}
+
+// OverlaySelection pins or excludes overlays of a component by name, e.g. gcp for common/istio/overlays/gcp.
+type OverlaySelection struct {
+	Pin     []string `yaml:"pin,omitempty"`     // overlays always used, whatever the provider and options
+	Exclude []string `yaml:"exclude,omitempty"` // overlays never used
+}
+
+// GetOverlaySelection returns the selection of the component at path, with or without /base.
+func (c *Config) GetOverlaySelection(path string) OverlaySelection {
+	return c.Spec.OverlaySelection[strings.TrimSuffix(path, string(os.PathSeparator)+"base")]
+}
+
+// SelectedOverlays returns spec.overlays without the excluded overlays, followed by the pinned overlays
+// of the components in spec.components that are not in spec.overlays.
+func (c *Config) SelectedOverlays() []string {
+	selected := make([]string, 0)
+	included := make(map[string]bool)
+
+	for _, overlay := range c.Spec.Overlays {
+		overlaysIndex := strings.Index(overlay, string(os.PathSeparator)+"overlays")
+		if overlaysIndex == -1 {
+			continue
+		}
+
+		excluded := false
+		for _, name := range c.GetOverlaySelection(overlay[:overlaysIndex]).Exclude {
+			if filepath.Base(overlay) == name {
+				excluded = true
+			}
+		}
+		if excluded || included[overlay] {
+			continue
+		}
+
+		selected = append(selected, overlay)
+		included[overlay] = true
+	}
+
+	for _, component := range c.Spec.Components {
+		componentPath := strings.TrimSuffix(component, string(os.PathSeparator)+"base")
+		for _, name := range c.GetOverlaySelection(componentPath).Pin {
+			overlay := filepath.Join(componentPath, "overlays", name)
+			if included[overlay] {
+				continue
+			}
+
+			selected = append(selected, overlay)
+			included[overlay] = true
+		}
+	}
+
+	return selected
+}
+
+// ParamsFiles are the params files of a config, e.g. base, environment and secrets. They are merged in order,
+// so the values of later files win. It is written as a list, a single path is also accepted when reading.
+type ParamsFiles []string
+
+// UnmarshalJSON accepts a single path or a list, FromFile converts the yaml to json first.
+func (p *ParamsFiles) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		*p = ParamsFiles{path}
+		return nil
+	}
+
+	var paths []string
+	if err := json.Unmarshal(data, &paths); err != nil {
+		return fmt.Errorf("params must be a path or a list of paths")
+	}
+	*p = paths
+
+	return nil
+}
+
+// UnmarshalYAML accepts a single path or a list.
+func (p *ParamsFiles) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var path string
+	if err := unmarshal(&path); err == nil {
+		*p = ParamsFiles{path}
+		return nil
+	}
+
+	var paths []string
+	if err := unmarshal(&paths); err != nil {
+		return fmt.Errorf("params must be a path or a list of paths")
+	}
+	*p = paths
+
+	return nil
+}
+
+// Last returns the last params file, the one with the highest precedence.
+func (p ParamsFiles) Last() string {
+	if len(p) == 0 {
+		return ""
+	}
+
+	return p[len(p)-1]
+}
+
+func (p ParamsFiles) String() string {
+	return strings.Join(p, ", ")
 }
 
 func FromFile(path string) (config *Config, err error) {
@@ -65,10 +196,9 @@ func FromFile(path string) (config *Config, err error) {
 		return
 	}
 
-	config = &Config{}
-	err = yaml.Unmarshal(content, config)
+	config, _, err = FromBytes(content)
 	if err != nil {
-		return
+		return nil, fmt.Errorf("%v: %v", path, err.Error())
 	}
 
 	err = config.Validate()
@@ -87,12 +217,18 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("the manifests repo directory does not exist at %v", c.Spec.ManifestsRepo)
 	}
 
-	paramsExists, err := files.Exists(c.Spec.Params)
-	if err != nil {
-		return fmt.Errorf("unable to check if file exists at %v", c.Spec.Params)
+	if len(c.Spec.Params) == 0 {
+		return fmt.Errorf("configuration file error: no parameters file is set")
 	}
-	if !paramsExists {
-		return fmt.Errorf("configuration file error: the parameters file does not exist at %v", c.Spec.Params)
+
+	for _, params := range c.Spec.Params {
+		paramsExists, err := files.Exists(params)
+		if err != nil {
+			return fmt.Errorf("unable to check if file exists at %v", params)
+		}
+		if !paramsExists {
+			return fmt.Errorf("configuration file error: the parameters file does not exist at %v", params)
+		}
 	}
 
 	return nil
@@ -106,21 +242,28 @@ func (c *Config) AddOverlay(name string) {
 	c.Spec.Overlays = append(c.Spec.Overlays, name)
 }
 
+// GetOverlayComponents returns the components in the order of spec.components, each with its overlays
+// in the order of SelectedOverlays, so the kustomization is the same on every build.
 func (c *Config) GetOverlayComponents(skipOverlayComponent string) []*SimpleOverlayedComponent {
 	overlayedComponents := make([]*SimpleOverlayedComponent, 0)
 
 	mappedComponents := make(map[string]*SimpleOverlayedComponent)
+	componentNames := make([]string, 0)
 
 	for _, component := range c.Spec.Components {
 		if component == skipOverlayComponent {
 			continue
 		}
 		formattedName := strings.TrimSuffix(component, string(os.PathSeparator)+"base")
+		if _, ok := mappedComponents[formattedName]; !ok {
+			componentNames = append(componentNames, formattedName)
+		}
 		mappedComponents[formattedName] = CreateSimpleOverlayedComponent(component)
 	}
 
-	for i := range c.Spec.Overlays {
-		overlay := c.Spec.Overlays[i]
+	selectedOverlays := c.SelectedOverlays()
+	for i := range selectedOverlays {
+		overlay := selectedOverlays[i]
 		overlaysIndex := strings.Index(overlay, string(os.PathSeparator)+"overlays")
 		formattedName := overlay[:overlaysIndex]
 
@@ -129,8 +272,8 @@ func (c *Config) GetOverlayComponents(skipOverlayComponent string) []*SimpleOver
 		}
 	}
 
-	for key := range mappedComponents {
-		overlayedComponents = append(overlayedComponents, mappedComponents[key])
+	for _, name := range componentNames {
+		overlayedComponents = append(overlayedComponents, mappedComponents[name])
 	}
 
 	return overlayedComponents
@@ -150,8 +293,9 @@ func (c *Config) GetOverlayComponent(overlayComponentGet string) []*SimpleOverla
 		}
 	}
 
-	for i := range c.Spec.Overlays {
-		overlay := c.Spec.Overlays[i]
+	selectedOverlays := c.SelectedOverlays()
+	for i := range selectedOverlays {
+		overlay := selectedOverlays[i]
 		overlaysIndex := strings.Index(overlay, string(os.PathSeparator)+"overlays")
 		formattedName := overlay[:overlaysIndex]
 
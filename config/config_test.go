@@ -0,0 +1,59 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_GetOverlayComponents(t *testing.T) {
+	config := &Config{
+		Spec: ConfigSpec{
+			Components: []string{"common/istio-crds/base", "common/istio/base", "cert-manager/base", "storage/base"},
+			Overlays:   []string{"storage/overlays/gcp", "common/istio/overlays/https", "common/istio/overlays/gcp"},
+		},
+	}
+
+	for i := 0; i < 10; i++ {
+		parts := make([]string, 0)
+		for _, component := range config.GetOverlayComponents("cert-manager/base") {
+			for _, part := range component.PartsSkipFirst() {
+				parts = append(parts, *part)
+			}
+		}
+
+		assert.Equal(t, []string{
+			"common/istio-crds/base",
+			"common/istio/overlays/https",
+			"common/istio/overlays/gcp",
+			"storage/overlays/gcp",
+		}, parts)
+	}
+}
+
+func TestConfig_SelectedOverlays(t *testing.T) {
+	config, _, err := FromBytes([]byte(`apiVersion: ` + APIVersion + `
+kind: OpDef
+spec:
+  components:
+  - common/istio/base
+  - storage/base
+  overlays:
+  - common/istio/overlays/https
+  - common/istio/overlays/gcp
+  - storage/overlays/gcp
+  overlaySelection:
+    common/istio:
+      exclude: [https]
+    storage:
+      pin: [nfs]
+`))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{
+		"common/istio/overlays/gcp",
+		"storage/overlays/gcp",
+		"storage/overlays/nfs",
+	}, config.SelectedOverlays())
+	assert.Equal(t, []string{"nfs"}, config.GetOverlaySelection("storage/base").Pin)
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	yamlv2 "gopkg.in/yaml.v2"
+)
+
+const (
+	// Group is the api group of config.yaml.
+	Group = "opdef.apps.onepanel.io"
+	// Kind is the kind of config.yaml.
+	Kind = "OpDef"
+	// APIVersionV1Alpha1 is the first version of config.yaml, with a single params file.
+	APIVersionV1Alpha1 = Group + "/v1alpha1"
+	// APIVersionV1Alpha2 has a list of params files and rejects unknown fields.
+	APIVersionV1Alpha2 = Group + "/v1alpha2"
+	// APIVersion is the version of config.yaml written by this CLI. Config is its Go type.
+	APIVersion = APIVersionV1Alpha2
+)
+
+// TypeMeta is the part of config.yaml that is the same in every version.
+type TypeMeta struct {
+	ApiVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+}
+
+// ConfigV1Alpha1 is config.yaml with apiVersion opdef.apps.onepanel.io/v1alpha1.
+type ConfigV1Alpha1 struct {
+	ApiVersion string             `yaml:"apiVersion"`
+	Kind       string             `yaml:"kind"`
+	Spec       ConfigSpecV1Alpha1 `yaml:"spec"`
+}
+
+// ConfigSpecV1Alpha1 is the spec of ConfigV1Alpha1.
+// Params was a single path, a list is accepted as some versions of the CLI wrote one.
+type ConfigSpecV1Alpha1 struct {
+	ManifestsRepo string      `yaml:"manifestsRepo"`
+	Params        ParamsFiles `yaml:"params"`
+	Components    []string    `yaml:"components"`
+	Overlays      []string    `yaml:"overlays"`
+}
+
+// Upgrade converts the config to opdef.apps.onepanel.io/v1alpha2.
+func (c *ConfigV1Alpha1) Upgrade() (interface{}, error) {
+	return &Config{
+		ApiVersion: APIVersionV1Alpha2,
+		Kind:       c.Kind,
+		Spec: ConfigSpec{
+			ManifestsRepo: c.Spec.ManifestsRepo,
+			Params:        c.Spec.Params,
+			Components:    c.Spec.Components,
+			Overlays:      c.Spec.Overlays,
+		},
+	}, nil
+}
+
+// upgradable is a config of a version older than APIVersion.
+type upgradable interface {
+	// Upgrade converts the config to the next version.
+	Upgrade() (interface{}, error)
+}
+
+// apiVersions are the supported versions of config.yaml, oldest first, with how to decode each of them.
+var apiVersions = []struct {
+	name   string
+	decode func(data []byte) (interface{}, error)
+}{
+	{
+		name: APIVersionV1Alpha1,
+		decode: func(data []byte) (interface{}, error) {
+			config := &ConfigV1Alpha1{}
+			return config, yaml.Unmarshal(data, config)
+		},
+	},
+	{
+		name: APIVersionV1Alpha2,
+		decode: func(data []byte) (interface{}, error) {
+			config := &Config{}
+			return config, unmarshalStrict(data, config)
+		},
+	},
+}
+
+// unmarshalStrict is yaml.Unmarshal, except that fields without a matching struct field are an error.
+func unmarshalStrict(data []byte, value interface{}) error {
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.DisallowUnknownFields()
+
+	return decoder.Decode(value)
+}
+
+// FromBytes reads a config of any supported version and converts it to APIVersion.
+// It returns the version the config had.
+func FromBytes(data []byte) (config *Config, version string, err error) {
+	typeMeta := &TypeMeta{}
+	if err := yaml.Unmarshal(data, typeMeta); err != nil {
+		return nil, "", err
+	}
+
+	version = typeMeta.ApiVersion
+	if version == "" {
+		return nil, "", fmt.Errorf("apiVersion is not set, expected %v", APIVersion)
+	}
+	if typeMeta.Kind != Kind {
+		return nil, "", fmt.Errorf("kind is %v, expected %v", typeMeta.Kind, Kind)
+	}
+
+	index := -1
+	for i, apiVersion := range apiVersions {
+		if apiVersion.name == version {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		if isNewerAPIVersion(version, APIVersion) {
+			return nil, "", fmt.Errorf("apiVersion %v is newer than %v, the latest this version of opctl supports. Upgrade opctl", version, APIVersion)
+		}
+
+		return nil, "", fmt.Errorf("unknown apiVersion %v, expected %v", version, APIVersion)
+	}
+
+	decoded, err := apiVersions[index].decode(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to read %v config: %v", version, err.Error())
+	}
+
+	for {
+		if current, ok := decoded.(*Config); ok {
+			return current, version, nil
+		}
+
+		old, ok := decoded.(upgradable)
+		if !ok {
+			return nil, "", fmt.Errorf("unable to convert %v config to %v", version, APIVersion)
+		}
+
+		decoded, err = old.Upgrade()
+		if err != nil {
+			return nil, "", fmt.Errorf("unable to convert %v config to %v: %v", version, APIVersion, err.Error())
+		}
+	}
+}
+
+// Migrate upgrades the config file at path to APIVersion, if it has an older version.
+// The original file is kept next to it, e.g. config.yaml.v1alpha1.bak. backupPath is empty if there was nothing to do.
+func Migrate(path string) (version, backupPath string, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	config, version, err := FromBytes(data)
+	if err != nil {
+		return "", "", err
+	}
+	if version == APIVersion {
+		return version, "", nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	backupPath = fmt.Sprintf("%v.%v.bak", path, version[strings.LastIndex(version, "/")+1:])
+	if err := ioutil.WriteFile(backupPath, data, info.Mode()); err != nil {
+		return "", "", fmt.Errorf("unable to back up %v: %v", path, err.Error())
+	}
+
+	upgraded, err := yamlv2.Marshal(config)
+	if err != nil {
+		return "", "", err
+	}
+	if err := ioutil.WriteFile(path, upgraded, info.Mode()); err != nil {
+		return "", "", err
+	}
+
+	return version, backupPath, nil
+}
+
+var apiVersionRegex = regexp.MustCompile(`^v(\d+)(?:(alpha|beta)(\d+))?$`)
+
+// isNewerAPIVersion is true if version is in the group of config.yaml and has a higher priority than current,
+// following the Kubernetes ordering, e.g. v1alpha1 < v1alpha2 < v1beta1 < v1 < v2alpha1.
+func isNewerAPIVersion(version, current string) bool {
+	versionGroup, versionName := splitAPIVersion(version)
+	currentGroup, currentName := splitAPIVersion(current)
+	if versionGroup != currentGroup {
+		return false
+	}
+
+	versionParts := apiVersionRegex.FindStringSubmatch(versionName)
+	currentParts := apiVersionRegex.FindStringSubmatch(currentName)
+	if versionParts == nil || currentParts == nil {
+		return false
+	}
+
+	stability := map[string]int{"alpha": 0, "beta": 1, "": 2}
+	versionMajor, _ := strconv.Atoi(versionParts[1])
+	currentMajor, _ := strconv.Atoi(currentParts[1])
+	if versionMajor != currentMajor {
+		return versionMajor > currentMajor
+	}
+	if stability[versionParts[2]] != stability[currentParts[2]] {
+		return stability[versionParts[2]] > stability[currentParts[2]]
+	}
+
+	versionMinor, _ := strconv.Atoi(versionParts[3])
+	currentMinor, _ := strconv.Atoi(currentParts[3])
+
+	return versionMinor > currentMinor
+}
+
+func splitAPIVersion(apiVersion string) (group, name string) {
+	index := strings.LastIndex(apiVersion, "/")
+	if index == -1 {
+		return "", apiVersion
+	}
+
+	return apiVersion[:index], apiVersion[index+1:]
+}
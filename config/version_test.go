@@ -0,0 +1,64 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "opctl-config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	original := `apiVersion: opdef.apps.onepanel.io/v1alpha1
+kind: OpDef
+spec:
+  manifestsRepo: .onepanel/manifests/cache/v0.12.0
+  params: params.yaml
+  components:
+  - common/application/base
+  overlays:
+  - common/application/overlays/aks
+`
+	path := filepath.Join(dir, "config.yaml")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(original), 0644))
+
+	version, backupPath, err := Migrate(path)
+	assert.Nil(t, err)
+	assert.Equal(t, APIVersionV1Alpha1, version)
+	assert.Equal(t, path+".v1alpha1.bak", backupPath)
+
+	backup, err := ioutil.ReadFile(backupPath)
+	assert.Nil(t, err)
+	assert.Equal(t, original, string(backup))
+
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	config, version, err := FromBytes(data)
+	assert.Nil(t, err)
+	assert.Equal(t, APIVersion, version)
+	assert.Equal(t, ParamsFiles{"params.yaml"}, config.Spec.Params)
+	assert.Equal(t, []string{"common/application/overlays/aks"}, config.Spec.Overlays)
+
+	_, backupPath, err = Migrate(path)
+	assert.Nil(t, err)
+	assert.Empty(t, backupPath)
+}
+
+func TestFromBytes_Errors(t *testing.T) {
+	_, _, err := FromBytes([]byte("kind: OpDef\nspec: {}\n"))
+	assert.EqualError(t, err, "apiVersion is not set, expected "+APIVersion)
+
+	_, _, err = FromBytes([]byte("apiVersion: opdef.apps.onepanel.io/v1beta1\nkind: OpDef\n"))
+	assert.Contains(t, err.Error(), "newer than "+APIVersion)
+
+	_, _, err = FromBytes([]byte("apiVersion: example.com/v1\nkind: OpDef\n"))
+	assert.Contains(t, err.Error(), "unknown apiVersion")
+
+	_, _, err = FromBytes([]byte("apiVersion: " + APIVersion + "\nkind: OpDef\nspec:\n  manifestRepo: .onepanel\n"))
+	assert.Contains(t, err.Error(), "manifestRepo")
+}
@@ -1,7 +1,9 @@
 package files
 
 import (
+	"archive/tar"
 	"archive/zip"
+	"compress/gzip"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -270,3 +272,153 @@ func Unzip(src string, dest string) ([]string, error) {
 	}
 	return filenames, nil
 }
+
+// Untar will decompress a gzipped tar archive, moving all files and folders
+// within the archive (parameter 1) to an output directory (parameter 2).
+// Symlinks and other special files are skipped.
+func Untar(src string, dest string) ([]string, error) {
+	var filenames []string
+
+	file, err := os.Open(src)
+	if err != nil {
+		return filenames, err
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return filenames, err
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return filenames, err
+		}
+
+		fpath := filepath.Join(dest, header.Name)
+
+		// Same check as Unzip, the archive must not write outside of dest.
+		// Archives made with tar -C dir . have a ./ entry, which is dest itself.
+		if fpath != filepath.Clean(dest) && !strings.HasPrefix(fpath, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return filenames, fmt.Errorf("%s: illegal file path", fpath)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			filenames = append(filenames, fpath)
+			if err := os.MkdirAll(fpath, os.ModePerm); err != nil {
+				return filenames, err
+			}
+		case tar.TypeReg:
+			filenames = append(filenames, fpath)
+			if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+				return filenames, err
+			}
+
+			outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+			if err != nil {
+				return filenames, err
+			}
+
+			_, err = io.Copy(outFile, tarReader)
+			outFile.Close()
+
+			if err != nil {
+				return filenames, err
+			}
+		}
+	}
+
+	return filenames, nil
+}
+
+// Tar writes the files and folders within src (parameter 1) to a gzipped tar archive at dest (parameter 2).
+// Paths in the archive are relative to src. Modification times and owners are left out so the same
+// directory always produces the same archive.
+func Tar(src string, dest string) error {
+	file, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if relativePath == "." {
+			return nil
+		}
+
+		header := &tar.Header{
+			Name: filepath.ToSlash(relativePath),
+			Mode: int64(info.Mode().Perm()),
+		}
+
+		switch {
+		case info.IsDir():
+			header.Name += "/"
+			header.Typeflag = tar.TypeDir
+			return tarWriter.WriteHeader(header)
+		case info.Mode().IsRegular():
+			header.Typeflag = tar.TypeReg
+			header.Size = info.Size()
+			if err := tarWriter.WriteHeader(header); err != nil {
+				return err
+			}
+
+			in, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer in.Close()
+
+			_, err = io.Copy(tarWriter, in)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+
+	return gzipWriter.Close()
+}
+
+// DirectorySize returns the total size in bytes of the regular files within path.
+func DirectorySize(path string) (int64, error) {
+	var size int64
+
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return size, err
+}
@@ -1,6 +1,7 @@
 package files
 
 import (
+	"fmt"
 	"io"
 	"net/http"
 	"os"
@@ -15,6 +16,10 @@ func DownloadFile(filepath string, url string) error {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("downloading %v: unexpected status %v", url, resp.Status)
+	}
+
 	// Create the file
 	out, err := os.Create(filepath)
 	if err != nil {
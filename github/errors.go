@@ -0,0 +1,52 @@
+package github
+
+import (
+	"fmt"
+	"time"
+)
+
+// NotFoundError is returned when the API responds with 404, e.g. for a repository that does not exist
+// or is private and no token is set.
+type NotFoundError struct {
+	Url string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%v was not found. If the repository is private, set GITHUB_TOKEN", e.Url)
+}
+
+// TagNotFoundError is returned when the repository has no release for a tag.
+type TagNotFoundError struct {
+	Tag string
+}
+
+func (e *TagNotFoundError) Error() string {
+	return fmt.Sprintf("there is no release with the tag '%v'. Run opctl manifests releases to see the available tags", e.Tag)
+}
+
+// RateLimitError is returned when the API rate limit is used up.
+type RateLimitError struct {
+	Limit         string
+	Reset         time.Time
+	Authenticated bool
+}
+
+func (e *RateLimitError) Error() string {
+	message := fmt.Sprintf("github API rate limit of %v requests exceeded, it resets at %v", e.Limit, e.Reset.Local().Format(time.Kitchen))
+	if !e.Authenticated {
+		message += ". Set GITHUB_TOKEN to get a higher limit"
+	}
+
+	return message
+}
+
+// StatusError is returned for any other unexpected status.
+type StatusError struct {
+	Url        string
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("github API %v responded with %v: %v", e.Url, e.StatusCode, e.Message)
+}
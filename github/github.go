@@ -2,42 +2,166 @@ package github
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// linkNextRegex finds the url of the next page in a Link header.
+var linkNextRegex = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+type Asset struct {
+	Url                string `json:"url"` // API url, downloads the asset with Accept: application/octet-stream
+	Name               string `json:"name"`
+	Size               int64  `json:"size"`
+	BrowserDownloadUrl string `json:"browser_download_url"`
+}
+
 type Release struct {
-	Url        string `json:"url"`
-	Name       string `json:"name"`
-	TagName    string `json:"tag_name"`
-	CreatedAt  string `json:"created_at"`
-	TarBallUrl string `json:"tarball_url"`
-	ZipBallUrl string `json:"zipball_url"`
+	Url        string  `json:"url"`
+	Name       string  `json:"name"`
+	TagName    string  `json:"tag_name"`
+	CreatedAt  string  `json:"created_at"`
+	TarBallUrl string  `json:"tarball_url"`
+	ZipBallUrl string  `json:"zipball_url"`
+	Prerelease bool    `json:"prerelease"`
+	Draft      bool    `json:"draft"`
+	Assets     []Asset `json:"assets"`
+}
+
+// FindAsset returns the asset called name, or nil if the release does not have it.
+func (r *Release) FindAsset(name string) *Asset {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i]
+		}
+	}
+
+	return nil
 }
 
+const (
+	// DefaultApiUrl is the API of github.com. GitHub Enterprise servers serve it under https://<host>/api/v3.
+	DefaultApiUrl = "https://api.github.com"
+	// TokenEnv is the environment variable holding the token used to authenticate with the API.
+	TokenEnv = "GITHUB_TOKEN"
+)
+
 type Github struct {
 	repoUrl string
+	token   string
+	client  *http.Client
 }
 
+// New creates a client for the repository API at url, e.g. https://api.github.com/repos/onepanelio/manifests.
+// The token in GITHUB_TOKEN is used, if it is set.
 func New(url string) (*Github, error) {
-	return &Github{repoUrl: url}, nil
+	return NewWithToken(url, os.Getenv(TokenEnv))
 }
 
-func (g *Github) GetRelease(url string) (release *Release, err error) {
-	response, err := http.Get(url)
+// NewWithToken creates a client for the repository API at url that authenticates with token.
+// An empty token makes unauthenticated requests, which have a lower rate limit.
+func NewWithToken(url, token string) (*Github, error) {
+	if url == "" {
+		return nil, fmt.Errorf("github repository url is empty")
+	}
+
+	return &Github{
+		repoUrl: strings.TrimSuffix(url, "/"),
+		token:   token,
+		client:  http.DefaultClient,
+	}, nil
+}
+
+// RepositoryUrl returns the API url of repository, e.g. onepanelio/manifests, on the API at apiUrl.
+// An empty apiUrl is DefaultApiUrl.
+func RepositoryUrl(apiUrl, repository string) string {
+	if apiUrl == "" {
+		apiUrl = DefaultApiUrl
+	}
+
+	return strings.TrimSuffix(apiUrl, "/") + "/repos/" + strings.Trim(repository, "/")
+}
+
+// get sends an authenticated GET request and checks the response status.
+// The caller must close the body of the returned response.
+func (g *Github) get(url, accept string) (*http.Response, error) {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Accept", accept)
+	if g.token != "" {
+		request.Header.Set("Authorization", "token "+g.token)
+	}
+
+	response, err := g.client.Do(request)
 	if err != nil {
-		return
+		return nil, err
+	}
+
+	if response.StatusCode >= 200 && response.StatusCode <= 299 {
+		return response, nil
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, &NotFoundError{Url: url}
+	}
+
+	if (response.StatusCode == http.StatusForbidden || response.StatusCode == http.StatusTooManyRequests) &&
+		response.Header.Get("X-RateLimit-Remaining") == "0" {
+		rateLimitError := &RateLimitError{
+			Limit:         response.Header.Get("X-RateLimit-Limit"),
+			Authenticated: g.token != "",
+		}
+		if reset, err := strconv.ParseInt(response.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			rateLimitError.Reset = time.Unix(reset, 0)
+		}
+
+		return nil, rateLimitError
+	}
+
+	body := struct {
+		Message string `json:"message"`
+	}{}
+	data, _ := ioutil.ReadAll(io.LimitReader(response.Body, 4096))
+	if err := json.Unmarshal(data, &body); err != nil || body.Message == "" {
+		body.Message = response.Status
 	}
 
-	data, err := ioutil.ReadAll(response.Body)
+	return nil, &StatusError{Url: url, StatusCode: response.StatusCode, Message: body.Message}
+}
+
+// getJSON gets url and decodes the response into v.
+func (g *Github) getJSON(url string, v interface{}) (*http.Response, error) {
+	response, err := g.get(url, "application/vnd.github.v3+json")
 	if err != nil {
-		return
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(v); err != nil {
+		return nil, fmt.Errorf("unable to parse the response of %v: %v", url, err.Error())
 	}
 
+	return response, nil
+}
+
+func (g *Github) GetRelease(url string) (release *Release, err error) {
 	release = &Release{}
-	err = json.Unmarshal(data, release)
+	if _, err := g.getJSON(url, release); err != nil {
+		return nil, err
+	}
 
-	return
+	return release, nil
 }
 
 func (g *Github) GetLatestRelease() (release *Release, err error) {
@@ -45,5 +169,59 @@ func (g *Github) GetLatestRelease() (release *Release, err error) {
 }
 
 func (g *Github) GetReleaseByTag(tag string) (release *Release, err error) {
-	return g.GetRelease(g.repoUrl + "/releases/tags/" + tag)
+	release, err = g.GetRelease(g.repoUrl + "/releases/tags/" + tag)
+	if _, ok := err.(*NotFoundError); ok {
+		return nil, &TagNotFoundError{Tag: tag}
+	}
+
+	return release, err
+}
+
+// ListReleases returns all published releases, newest first, following the pagination of the API.
+// Prereleases are only included if includePrereleases is true.
+func (g *Github) ListReleases(includePrereleases bool) ([]*Release, error) {
+	releases := make([]*Release, 0)
+
+	url := g.repoUrl + "/releases?per_page=100"
+	for url != "" {
+		page := make([]*Release, 0)
+		response, err := g.getJSON(url, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, release := range page {
+			if release.Draft || (release.Prerelease && !includePrereleases) {
+				continue
+			}
+			releases = append(releases, release)
+		}
+
+		url = ""
+		if match := linkNextRegex.FindStringSubmatch(response.Header.Get("Link")); match != nil {
+			url = match[1]
+		}
+	}
+
+	return releases, nil
+}
+
+// DownloadFile downloads url, e.g. the zipball of a release or an asset, to path.
+// The token is sent, so files of private repositories can be downloaded.
+func (g *Github) DownloadFile(path, url string) error {
+	response, err := g.get(url, "application/octet-stream")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, response.Body)
+
+	return err
 }
@@ -0,0 +1,87 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGithub_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("Authorization") == "token exhausted":
+			w.Header().Set("X-RateLimit-Limit", "5000")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", "1600000000")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message": "API rate limit exceeded"}`))
+		case strings.HasSuffix(r.URL.Path, "/releases/tags/v0.1.0"):
+			_, _ = w.Write([]byte(`{"tag_name": "v0.1.0", "zipball_url": "https://example.com/v0.1.0.zip"}`))
+		case strings.HasSuffix(r.URL.Path, "/releases/latest"):
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"message": "Server Error"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "Not Found"}`))
+		}
+	}))
+	defer server.Close()
+
+	api, err := NewWithToken(RepositoryUrl(server.URL, "onepanelio/manifests"), "")
+	assert.Nil(t, err)
+
+	release, err := api.GetReleaseByTag("v0.1.0")
+	assert.Nil(t, err)
+	assert.Equal(t, "v0.1.0", release.TagName)
+
+	_, err = api.GetReleaseByTag("v9.9.9")
+	assert.IsType(t, &TagNotFoundError{}, err)
+
+	_, err = api.GetLatestRelease()
+	assert.IsType(t, &StatusError{}, err)
+	assert.Contains(t, err.Error(), "Server Error")
+
+	exhausted, err := NewWithToken(RepositoryUrl(server.URL, "onepanelio/manifests"), "exhausted")
+	assert.Nil(t, err)
+
+	_, err = exhausted.GetReleaseByTag("v0.1.0")
+	rateLimitError, ok := err.(*RateLimitError)
+	assert.True(t, ok)
+	assert.Equal(t, "5000", rateLimitError.Limit)
+	assert.True(t, rateLimitError.Reset.Equal(time.Unix(1600000000, 0)))
+	assert.True(t, rateLimitError.Authenticated)
+}
+
+func TestGithub_ListReleases(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "token secret", r.Header.Get("Authorization"))
+
+		if r.URL.Query().Get("page") == "2" {
+			_, _ = w.Write([]byte(`[{"tag_name": "v0.1.0"}, {"tag_name": "v0.2.0-rc.1", "prerelease": true}]`))
+			return
+		}
+
+		w.Header().Set("Link", fmt.Sprintf(`<%v/repos/onepanelio/manifests/releases?per_page=100&page=2>; rel="next"`, server.URL))
+		_, _ = w.Write([]byte(`[{"tag_name": "v0.3.0"}, {"tag_name": "v0.4.0", "draft": true}]`))
+	}))
+	defer server.Close()
+
+	api, err := NewWithToken(RepositoryUrl(server.URL, "onepanelio/manifests"), "secret")
+	assert.Nil(t, err)
+
+	releases, err := api.ListReleases(false)
+	assert.Nil(t, err)
+	assert.Len(t, releases, 2)
+	assert.Equal(t, "v0.3.0", releases[0].TagName)
+	assert.Equal(t, "v0.1.0", releases[1].TagName)
+
+	releases, err = api.ListReleases(true)
+	assert.Nil(t, err)
+	assert.Len(t, releases, 3)
+}
@@ -2,10 +2,13 @@ package manifest
 
 import (
 	"fmt"
+	"github.com/onepanelio/cli/config"
 	"github.com/onepanelio/cli/files"
 	"github.com/onepanelio/cli/util"
 	"log"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -42,6 +45,16 @@ type Builder struct {
 	manifest            *Manifest
 	overlayedComponents map[string]*OverlayedComponent
 	overlayContenders   []string
+	provider            string
+	requiredBy          map[string]string // the component that required each component, empty if it was added directly
+	overlaySelection    map[string]config.OverlaySelection
+	overlayReasons      map[string]string // why each overlay was selected, e.g. contender gcp
+}
+
+// OverlayReason is why an overlay was selected, see Builder.OverlayReasons.
+type OverlayReason struct {
+	Overlay string
+	Reason  string
 }
 
 func CreateBuilder(manifest *Manifest) *Builder {
@@ -49,11 +62,19 @@ func CreateBuilder(manifest *Manifest) *Builder {
 		manifest:            manifest,
 		overlayedComponents: make(map[string]*OverlayedComponent),
 		overlayContenders:   make([]string, 0),
+		requiredBy:          make(map[string]string),
+		overlaySelection:    make(map[string]config.OverlaySelection),
+		overlayReasons:      make(map[string]string),
 	}
 
 	return b
 }
 
+// SetProvider sets the provider the components are built for, see ComponentMetadata.Providers.
+func (b *Builder) SetProvider(provider string) {
+	b.provider = provider
+}
+
 func (b *Builder) addComponentSingle(componentPath string) error {
 	component := b.manifest.GetComponent(componentPath)
 
@@ -75,18 +96,164 @@ func (b *Builder) addComponentSingle(componentPath string) error {
 	return nil
 }
 
-// AddComponent adds manifest components to the final result.
+// AddComponent adds manifest components to the final result, along with the components they require.
+// Components that have already been added are skipped. Nothing is added if a component is unknown,
+// is not supported for the provider, conflicts with another one or is part of a dependency cycle.
 func (b *Builder) AddComponent(componentPaths ...string) error {
 	for _, componentPath := range componentPaths {
-		if err := b.addComponentSingle(componentPath); err != nil {
+		added := make([]string, 0)
+		requiredBy := make(map[string]string)
+		if err := b.resolveComponent(componentPath, "", nil, &added, requiredBy); err != nil {
+			return err
+		}
+
+		explain := func(path string) string {
+			return b.explain(path, requiredBy)
+		}
+		if err := b.checkConflicts(added, explain); err != nil {
 			return err
 		}
+
+		for _, path := range added {
+			if err := b.addComponentSingle(path); err != nil {
+				return err
+			}
+			b.requiredBy[path] = requiredBy[path]
+		}
 	}
 
 	return nil
 }
 
+// SupportsProvider returns false if the component at componentPath does not support the provider of b.
+// Unknown components return true, AddComponent reports them.
+func (b *Builder) SupportsProvider(componentPath string) bool {
+	component := b.manifest.GetComponent(componentPath)
+	if component == nil {
+		return true
+	}
+
+	return component.Metadata().SupportsProvider(b.provider)
+}
+
+// resolveComponent appends the component at path to added after the components it requires, unless it has already been added.
+// chain holds the components that led to it, to report cycles.
+func (b *Builder) resolveComponent(path, requiredByPath string, chain []string, added *[]string, requiredBy map[string]string) error {
+	for i, chainPath := range chain {
+		if chainPath == path {
+			return fmt.Errorf("dependency cycle: %v requires %v", strings.Join(chain[i:], " requires "), path)
+		}
+	}
+
+	if _, ok := b.overlayedComponents[path]; ok {
+		return nil
+	}
+	if _, ok := requiredBy[path]; ok {
+		return nil
+	}
+
+	component := b.manifest.GetComponent(path)
+	if component == nil {
+		if requiredByPath != "" {
+			return fmt.Errorf("unknown component '%v', required by '%v'. %v", path, requiredByPath, b.explain(requiredByPath, requiredBy))
+		}
+		return fmt.Errorf("unknown component '%v'", path)
+	}
+
+	requiredBy[path] = requiredByPath
+	if !component.Metadata().SupportsProvider(b.provider) {
+		return fmt.Errorf("component '%v' only supports the providers %v, not %v. %v", path, strings.Join(component.Metadata().Providers, ", "), b.provider, b.explain(path, requiredBy))
+	}
+
+	for _, required := range component.Metadata().Requires {
+		if err := b.resolveComponent(required, path, append(chain, path), added, requiredBy); err != nil {
+			return err
+		}
+	}
+
+	*added = append(*added, path)
+
+	return nil
+}
+
+// checkConflicts returns an error if one of the components in added conflicts with another one being added or already added.
+func (b *Builder) checkConflicts(added []string, explain func(path string) string) error {
+	paths := append([]string{}, added...)
+	for path := range b.overlayedComponents {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range added {
+		for _, other := range paths {
+			if path == other || !b.conflicts(path, other) {
+				continue
+			}
+
+			return fmt.Errorf("component '%v' conflicts with '%v'. %v %v", path, other, explain(path), explain(other))
+		}
+	}
+
+	return nil
+}
+
+// conflicts is true if either component declares a conflict with the other.
+func (b *Builder) conflicts(path, other string) bool {
+	for _, pair := range [][]string{{path, other}, {other, path}} {
+		component := b.manifest.GetComponent(pair[0])
+		if component == nil {
+			continue
+		}
+		for _, conflict := range component.Metadata().Conflicts {
+			if conflict == pair[1] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// explain returns why the component at path is part of the build, e.g.
+// "'istio' was added because 'argo' requires it, and 'argo' was added directly."
+// requiredBy holds the components being added, on top of the ones already added.
+func (b *Builder) explain(path string, requiredBy map[string]string) string {
+	lookup := func(path string) string {
+		if requiredByPath, ok := requiredBy[path]; ok {
+			return requiredByPath
+		}
+		return b.requiredBy[path]
+	}
+
+	requiredByPath := lookup(path)
+	if requiredByPath == "" {
+		return fmt.Sprintf("'%v' was added directly.", path)
+	}
+
+	reasons := make([]string, 0)
+	for current := path; lookup(current) != "" && len(reasons) <= len(b.manifest.components); current = lookup(current) {
+		reasons = append(reasons, fmt.Sprintf("'%v' requires '%v'", lookup(current), current))
+	}
+
+	return fmt.Sprintf("'%v' was added because %v.", path, strings.Join(reasons, ", "))
+}
+
+// SetOverlaySelection pins and excludes overlays of components by name, keyed by component path, as in config.yaml.
+// Build adds the pinned overlays of the added components and never selects the excluded ones.
+func (b *Builder) SetOverlaySelection(selection map[string]config.OverlaySelection) {
+	b.overlaySelection = make(map[string]config.OverlaySelection)
+	for path, componentSelection := range selection {
+		b.overlaySelection[strings.TrimSuffix(path, string(os.PathSeparator)+"base")] = componentSelection
+	}
+}
+
 func (b *Builder) AddOverlay(overlayPath string) error {
+	return b.addOverlay(overlayPath, "added directly")
+}
+
+// addOverlay adds the overlay at overlayPath, and its component if needed. reason is why the overlay is selected,
+// the first reason is kept if the overlay is added again.
+func (b *Builder) addOverlay(overlayPath, reason string) error {
 	overlay := b.manifest.GetOverlay(overlayPath)
 
 	if overlay == nil {
@@ -94,6 +261,9 @@ func (b *Builder) AddOverlay(overlayPath string) error {
 	}
 
 	componentPath := overlay.component.path
+	if b.isExcluded(overlay) {
+		return fmt.Errorf("overlay '%v' is %v, but it is excluded for component '%v' in config.yaml", overlayPath, reason, componentPath)
+	}
 
 	if _, ok := b.overlayedComponents[componentPath]; !ok {
 		if err := b.AddComponent(componentPath); err != nil {
@@ -102,17 +272,52 @@ func (b *Builder) AddOverlay(overlayPath string) error {
 	}
 
 	b.overlayedComponents[componentPath].AddOverlay(overlay)
+	if _, ok := b.overlayReasons[overlayPath]; !ok {
+		b.overlayReasons[overlayPath] = reason
+	}
 
 	return nil
 }
 
+// isExcluded is true if the overlay is excluded for its component, see SetOverlaySelection.
+func (b *Builder) isExcluded(overlay *Overlay) bool {
+	for _, name := range b.overlaySelection[overlay.component.path].Exclude {
+		if name == overlay.Name() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// OverlayReasons returns why each overlay of the added components was selected, in the order of GetOverlayComponents.
+func (b *Builder) OverlayReasons() []OverlayReason {
+	reasons := make([]OverlayReason, 0)
+	for _, overlayComponent := range b.GetOverlayComponents() {
+		for _, overlay := range overlayComponent.Overlays() {
+			reasons = append(reasons, OverlayReason{
+				Overlay: overlay.path,
+				Reason:  b.overlayReasons[overlay.path],
+			})
+		}
+	}
+
+	return reasons
+}
+
 func (b *Builder) AddCommonComponents(skipComponents ...string) error {
 	skipMap := make(map[string]bool)
 	for _, skip := range skipComponents {
 		skipMap[skip] = true
 	}
 
+	keys := make([]string, 0, len(b.manifest.components))
 	for key := range b.manifest.components {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
 		if _, ok := skipMap[key]; ok {
 			continue
 		}
@@ -129,16 +334,40 @@ func (b *Builder) AddCommonComponents(skipComponents ...string) error {
 	return nil
 }
 
+// GetOverlayComponents returns the added components, each one after the components it depends on
+// and alphabetically otherwise, so config.yaml and the kustomization are the same on every run.
 func (b *Builder) GetOverlayComponents() []*OverlayedComponent {
-	result := make([]*OverlayedComponent, 0)
+	paths := make([]string, 0, len(b.overlayedComponents))
+	for path := range b.overlayedComponents {
+		paths = append(paths, path)
+	}
 
-	for key := range b.overlayedComponents {
-		result = append(result, b.overlayedComponents[key])
+	sortedPaths, err := SortTopologically(paths, b.dependencies)
+	if err != nil {
+		// A cycle has no dependency order, fall back to alphabetical.
+		sort.Strings(paths)
+		sortedPaths = paths
+	}
+
+	result := make([]*OverlayedComponent, 0, len(sortedPaths))
+	for _, path := range sortedPaths {
+		result = append(result, b.overlayedComponents[path])
 	}
 
 	return result
 }
 
+// dependencies returns the components that have to come before the component at path: the ones it requires and
+// the custom resource definitions of the component, e.g. istio-crds for istio.
+func (b *Builder) dependencies(path string) []string {
+	dependencies := []string{path + "-crds"}
+	if component := b.manifest.GetComponent(path); component != nil {
+		dependencies = append(dependencies, component.Metadata().Requires...)
+	}
+
+	return dependencies
+}
+
 // AddOverlayContender adds potential overlays to the components being considered
 func (b *Builder) AddOverlayContender(contenders ...string) {
 	for _, contender := range contenders {
@@ -146,20 +375,112 @@ func (b *Builder) AddOverlayContender(contenders ...string) {
 	}
 }
 
+// Build selects the overlays of the added components. An overlay is selected if its name is one of the overlay contenders,
+// e.g. gcp selects istio/overlays/gcp but not istio/overlays/mygcp, unless it is excluded. Pinned overlays are always selected.
+// Overlays of the same component in a group of DefaultExclusiveOverlays or ComponentMetadata.ExclusiveOverlays can't be selected together.
 func (b *Builder) Build() error {
 	// Go through each overlay contender and component, and add the overlays
+	overlayPaths := make([]string, 0, len(b.manifest.overlays))
+	for key := range b.manifest.overlays {
+		overlayPaths = append(overlayPaths, key)
+	}
+	sort.Strings(overlayPaths)
+
 	for _, overlayContender := range b.overlayContenders {
-		for key := range b.manifest.overlays {
+		for _, key := range overlayPaths {
 			overlay := b.manifest.overlays[key]
 			if _, ok := b.overlayedComponents[overlay.component.path]; !ok {
 				continue
 			}
 
-			if strings.HasSuffix(overlay.path, overlayContender) {
-				if err := b.AddOverlay(overlay.path); err != nil {
-					return err
+			if overlay.Name() != overlayContender || b.isExcluded(overlay) {
+				continue
+			}
+
+			if err := b.addOverlay(overlay.path, "selected by contender "+overlayContender); err != nil {
+				return err
+			}
+		}
+	}
+
+	componentPaths := make([]string, 0, len(b.overlaySelection))
+	for path := range b.overlaySelection {
+		componentPaths = append(componentPaths, path)
+	}
+	sort.Strings(componentPaths)
+
+	for _, componentPath := range componentPaths {
+		if _, ok := b.overlayedComponents[componentPath]; !ok {
+			continue
+		}
+
+		for _, name := range b.overlaySelection[componentPath].Pin {
+			overlayPath := filepath.Join(componentPath, "overlays", name)
+			if b.manifest.GetOverlay(overlayPath) == nil {
+				return fmt.Errorf("overlay '%v' pinned for component '%v' in config.yaml does not exist", name, componentPath)
+			}
+
+			if err := b.addOverlay(overlayPath, "pinned in config.yaml"); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := b.checkExclusiveOverlays(); err != nil {
+		return err
+	}
+
+	return b.checkRequirements()
+}
+
+// checkExclusiveOverlays returns an error if a component has more than one overlay of a group of exclusive overlays.
+func (b *Builder) checkExclusiveOverlays() error {
+	for _, overlayComponent := range b.GetOverlayComponents() {
+		groups := append(append([][]string{}, DefaultExclusiveOverlays...), overlayComponent.component.Metadata().ExclusiveOverlays...)
+
+		for _, group := range groups {
+			selected := make([]string, 0)
+			for _, overlay := range overlayComponent.Overlays() {
+				for _, name := range group {
+					if overlay.Name() == name {
+						selected = append(selected, fmt.Sprintf("'%v' (%v)", overlay.path, b.overlayReasons[overlay.path]))
+					}
+				}
+			}
+
+			if len(selected) > 1 {
+				return fmt.Errorf("overlays %v of component '%v' can't be used together, only one of %v can be selected. "+
+					"Pin or exclude overlays with overlaySelection in config.yaml", strings.Join(selected, " and "), overlayComponent.component.path, strings.Join(group, ", "))
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkRequirements returns an error if a component does not support the provider, or requires an overlay contender that is not set.
+// Components can be added before the provider and overlay contenders are set, so they are checked once the build is done.
+func (b *Builder) checkRequirements() error {
+	for _, overlayComponent := range b.GetOverlayComponents() {
+		path := overlayComponent.component.path
+		metadata := overlayComponent.component.Metadata()
+
+		if !metadata.SupportsProvider(b.provider) {
+			return fmt.Errorf("component '%v' only supports the providers %v, not %v. %v", path, strings.Join(metadata.Providers, ", "), b.provider, b.explain(path, nil))
+		}
+
+		for _, requiredOverlay := range metadata.RequiresOverlays {
+			found := false
+			for _, contender := range b.overlayContenders {
+				if contender == requiredOverlay {
+					found = true
+					break
 				}
 			}
+
+			if !found {
+				return fmt.Errorf("component '%v' requires the %v overlay. %v", path, requiredOverlay, b.explain(path, nil))
+			}
 		}
 	}
 
@@ -167,9 +488,24 @@ func (b *Builder) Build() error {
 }
 
 func (b *Builder) GetYamls() []*util.DynamicYaml {
-	varsArray := make([]*util.DynamicYaml, 0)
+	return loadVarsYamls(b.GetVarsFilePaths())
+}
 
-	filePaths := b.GetVarsFilePaths()
+// GetComponentYamls is GetYamls for the components at componentPaths, with the overlays selected for them.
+func (b *Builder) GetComponentYamls(componentPaths ...string) []*util.DynamicYaml {
+	overlayedComponents := make([]*OverlayedComponent, 0)
+	for _, path := range componentPaths {
+		if overlayedComponent, ok := b.overlayedComponents[path]; ok {
+			overlayedComponents = append(overlayedComponents, overlayedComponent)
+		}
+	}
+
+	return loadVarsYamls(b.varsFilePaths(overlayedComponents))
+}
+
+// loadVarsYamls loads the vars files at filePaths with the defaults set and the hidden vars removed.
+func loadVarsYamls(filePaths []string) []*util.DynamicYaml {
+	varsArray := make([]*util.DynamicYaml, 0)
 
 	for _, path := range filePaths {
 		temp, err := util.LoadDynamicYamlFromFile(path)
@@ -192,10 +528,14 @@ func (b *Builder) GetYamls() []*util.DynamicYaml {
 
 // Gets all of the existing vars file paths.
 func (b *Builder) GetVarsFilePaths() []string {
+	return b.varsFilePaths(b.GetOverlayComponents())
+}
+
+// varsFilePaths returns the vars files of overlayedComponents and their overlays that exist.
+func (b *Builder) varsFilePaths(overlayedComponents []*OverlayedComponent) []string {
 	vars := make([]string, 0)
 
-	for key := range b.overlayedComponents {
-		overlayComponent := b.overlayedComponents[key]
+	for _, overlayComponent := range overlayedComponents {
 		vars = append(vars, overlayComponent.component.VarsFilePath())
 
 		for _, overlay := range overlayComponent.Overlays() {
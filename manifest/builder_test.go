@@ -0,0 +1,212 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onepanelio/cli/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestManifests(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "opctl-manifests")
+	assert.Nil(t, err)
+
+	for _, path := range []string{
+		"common/application/base",
+		"common/istio/base",
+		"common/istio/overlays/gcp",
+		"common/istio/overlays/https",
+		"common/istio-crds/base",
+		"common/monitoring/base",
+		"cert-manager/base",
+		"cert-manager/overlays/clouddns",
+		"storage/base",
+		"storage/overlays/gcp",
+		"storage/overlays/aks",
+	} {
+		assert.Nil(t, os.MkdirAll(filepath.Join(dir, path), os.ModePerm))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, path, "vars.yaml"), []byte("{}\n"), 0644))
+	}
+
+	return dir
+}
+
+func buildTestManifests(t *testing.T, dir string) *Builder {
+	loadedManifest, err := LoadManifest(dir)
+	assert.Nil(t, err)
+
+	builder := CreateBuilder(loadedManifest)
+	assert.Nil(t, builder.AddCommonComponents())
+	assert.Nil(t, builder.AddComponent("storage", "cert-manager"))
+	builder.AddOverlayContender("gcp", "https", "clouddns")
+	assert.Nil(t, builder.Build())
+
+	return builder
+}
+
+func TestBuilder_GetOverlayComponents(t *testing.T) {
+	dir := createTestManifests(t)
+	defer os.RemoveAll(dir)
+
+	builder := buildTestManifests(t, dir)
+
+	paths := make([]string, 0)
+	for _, overlayComponent := range builder.GetOverlayComponents() {
+		paths = append(paths, overlayComponent.Component().Path())
+		for _, overlay := range overlayComponent.Overlays() {
+			paths = append(paths, overlay.Path())
+		}
+	}
+
+	assert.Equal(t, []string{
+		"cert-manager",
+		"cert-manager/overlays/clouddns",
+		"common/application",
+		"common/istio-crds",
+		"common/istio",
+		"common/istio/overlays/gcp",
+		"common/istio/overlays/https",
+		"common/monitoring",
+		"storage",
+		"storage/overlays/gcp",
+	}, paths)
+
+	varsFilePaths := builder.GetVarsFilePaths()
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, varsFilePaths, buildTestManifests(t, dir).GetVarsFilePaths())
+	}
+}
+
+func TestSortTopologically(t *testing.T) {
+	dependencies := map[string][]string{
+		"argo":    {"istio", "missing"},
+		"istio":   {"crds"},
+		"modeldb": {"argo"},
+	}
+
+	sorted, err := SortTopologically([]string{"modeldb", "storage", "argo", "istio", "crds"}, func(name string) []string {
+		return dependencies[name]
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"crds", "istio", "argo", "modeldb", "storage"}, sorted)
+
+	dependencies["istio"] = []string{"modeldb"}
+	_, err = SortTopologically([]string{"modeldb", "storage", "argo", "istio"}, func(name string) []string {
+		return dependencies[name]
+	})
+	assert.EqualError(t, err, "dependency cycle between argo, istio, modeldb")
+}
+
+func TestBuilder_AddComponentDependencies(t *testing.T) {
+	dir := createTestManifests(t)
+	defer os.RemoveAll(dir)
+
+	for path, metadata := range map[string]string{
+		"argo":    "requires: [common/istio]\n",
+		"modeldb": "requires: [argo]\nconflicts: [mlflow]\n",
+		"mlflow":  "requires: [argo]\n",
+		"loop-a":  "requires: [loop-b]\n",
+		"loop-b":  "requires: [loop-a]\n",
+		"metallb": "providers: [minikube, microk8s]\n",
+	} {
+		assert.Nil(t, os.MkdirAll(filepath.Join(dir, path, "base"), os.ModePerm))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, path, MetadataFileName), []byte(metadata), 0644))
+	}
+
+	loadedManifest, err := LoadManifest(dir)
+	assert.Nil(t, err)
+
+	builder := CreateBuilder(loadedManifest)
+	builder.SetProvider("gke")
+	assert.Nil(t, builder.AddComponent("modeldb"))
+
+	paths := make([]string, 0)
+	for _, overlayComponent := range builder.GetOverlayComponents() {
+		paths = append(paths, overlayComponent.Component().Path())
+	}
+	assert.Equal(t, []string{"common/istio", "argo", "modeldb"}, paths)
+
+	err = builder.AddComponent("mlflow")
+	assert.EqualError(t, err, "component 'mlflow' conflicts with 'modeldb'. 'mlflow' was added directly. 'modeldb' was added directly.")
+
+	err = builder.AddComponent("loop-a")
+	assert.EqualError(t, err, "dependency cycle: loop-a requires loop-b requires loop-a")
+
+	assert.False(t, builder.SupportsProvider("metallb"))
+	assert.True(t, builder.SupportsProvider("argo"))
+	assert.True(t, builder.SupportsProvider("unknown"))
+
+	err = builder.AddComponent("metallb")
+	assert.EqualError(t, err, "component 'metallb' only supports the providers minikube, microk8s, not gke. 'metallb' was added directly.")
+	assert.Len(t, builder.GetOverlayComponents(), 3)
+
+	assert.Nil(t, builder.AddComponent("cert-manager"))
+	err = builder.Build()
+	assert.EqualError(t, err, "component 'cert-manager' requires the https overlay. 'cert-manager' was added directly.")
+
+	builder = CreateBuilder(loadedManifest)
+	assert.Nil(t, builder.AddComponent("argo"))
+	assert.Equal(t, "'common/istio' was added because 'argo' requires 'common/istio'.", builder.explain("common/istio", nil))
+}
+
+func TestBuilder_GetComponentYamls(t *testing.T) {
+	dir := createTestManifests(t)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "storage/base/vars.yaml"), []byte("storage:\n  size:\n    default: 20Gi\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "storage/overlays/gcp/vars.yaml"), []byte("storage:\n  class:\n    default: standard\n"), 0644))
+
+	builder := buildTestManifests(t, dir)
+	vars := builder.GetComponentYamls("storage")
+	assert.Len(t, vars, 2)
+	assert.Equal(t, "20Gi", vars[0].GetValue("storage.size").Value)
+	assert.Equal(t, "standard", vars[1].GetValue("storage.class").Value)
+
+	loadedManifest, err := LoadManifest(dir)
+	assert.Nil(t, err)
+	components := loadedManifest.Components()
+	assert.Equal(t, "cert-manager", components[0].Path())
+	assert.Equal(t, "clouddns", components[0].Overlays()[0].Name())
+}
+
+func TestBuilder_BuildOverlaySelection(t *testing.T) {
+	dir := createTestManifests(t)
+	defer os.RemoveAll(dir)
+
+	for _, path := range []string{"storage/overlays/mygcp", "storage/overlays/eks", "common/istio/overlays/eks"} {
+		assert.Nil(t, os.MkdirAll(filepath.Join(dir, path), os.ModePerm))
+	}
+
+	loadedManifest, err := LoadManifest(dir)
+	assert.Nil(t, err)
+
+	builder := CreateBuilder(loadedManifest)
+	assert.Nil(t, builder.AddComponent("storage", "common/istio"))
+	builder.SetOverlaySelection(map[string]config.OverlaySelection{
+		"storage":      {Pin: []string{"aks"}},
+		"common/istio": {Exclude: []string{"https"}},
+	})
+	builder.AddOverlayContender("gcp", "https")
+	assert.Nil(t, builder.Build())
+
+	assert.Equal(t, []OverlayReason{
+		{Overlay: "common/istio/overlays/gcp", Reason: "selected by contender gcp"},
+		{Overlay: "storage/overlays/gcp", Reason: "selected by contender gcp"},
+		{Overlay: "storage/overlays/aks", Reason: "pinned in config.yaml"},
+	}, builder.OverlayReasons())
+
+	err = builder.AddOverlay("common/istio/overlays/https")
+	assert.EqualError(t, err, "overlay 'common/istio/overlays/https' is added directly, but it is excluded for component 'common/istio' in config.yaml")
+
+	builder = CreateBuilder(loadedManifest)
+	assert.Nil(t, builder.AddComponent("storage", "common/istio"))
+	builder.SetOverlaySelection(map[string]config.OverlaySelection{"storage": {Pin: []string{"aks"}}})
+	builder.AddOverlayContender("eks")
+	err = builder.Build()
+	assert.EqualError(t, err, "overlays 'storage/overlays/eks' (selected by contender eks) and 'storage/overlays/aks' (pinned in config.yaml) "+
+		"of component 'storage' can't be used together, only one of aks, eks, gke, minikube, microk8s can be selected. "+
+		"Pin or exclude overlays with overlaySelection in config.yaml")
+}
@@ -0,0 +1,208 @@
+package manifest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/onepanelio/cli/files"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// CacheIndexFileName is the file in the manifests directory that records where each cached entry came from.
+	CacheIndexFileName = ".cache.yaml"
+	// buildCacheDirectoryName is the copy of the manifests build works in. It is not a cached source.
+	buildCacheDirectoryName = "cache"
+)
+
+// CacheIndexEntry is what is recorded about a cached entry when it is used by init.
+type CacheIndexEntry struct {
+	Source       string    `yaml:"source"`
+	Tag          string    `yaml:"tag,omitempty"`
+	DownloadedAt time.Time `yaml:"downloadedAt"`
+	LastUsedAt   time.Time `yaml:"lastUsedAt"`
+}
+
+// CacheEntry is a cached manifests directory.
+type CacheEntry struct {
+	Name string // name of the directory
+	Path string
+	Size int64 // in bytes
+	CacheIndexEntry
+}
+
+func loadCacheIndex(directoryPath string) (map[string]*CacheIndexEntry, error) {
+	index := make(map[string]*CacheIndexEntry)
+
+	data, err := ioutil.ReadFile(filepath.Join(directoryPath, CacheIndexFileName))
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("unable to read %v: %v", CacheIndexFileName, err.Error())
+	}
+
+	return index, nil
+}
+
+func writeCacheIndex(directoryPath string, index map[string]*CacheIndexEntry) error {
+	data, err := yaml.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(directoryPath, CacheIndexFileName), data, 0644)
+}
+
+// RecordCacheEntry records that source, which has been moved to directoryPath, was used now.
+func RecordCacheEntry(directoryPath string, source Source) error {
+	manifestPath, err := source.GetManifestPath()
+	if err != nil {
+		return err
+	}
+
+	index, err := loadCacheIndex(directoryPath)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	name := filepath.Base(manifestPath)
+	entry, ok := index[name]
+	if !ok {
+		entry = &CacheIndexEntry{DownloadedAt: now}
+		index[name] = entry
+	}
+	entry.Source = source.GetSourceType()
+	entry.Tag = source.GetTag()
+	entry.LastUsedAt = now
+
+	return writeCacheIndex(directoryPath, index)
+}
+
+// ListCache returns the cached manifests in directoryPath, most recently used first.
+// Entries that are not in the index, e.g. from older CLI versions, use the modification time of the directory.
+func ListCache(directoryPath string) ([]*CacheEntry, error) {
+	infos, err := ioutil.ReadDir(directoryPath)
+	if os.IsNotExist(err) {
+		return []*CacheEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := loadCacheIndex(directoryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*CacheEntry, 0)
+	for _, info := range infos {
+		// Hidden entries are the index and temporary directories of downloads in progress.
+		if !info.IsDir() || strings.HasPrefix(info.Name(), ".") || info.Name() == buildCacheDirectoryName {
+			continue
+		}
+
+		entry := &CacheEntry{
+			Name: info.Name(),
+			Path: filepath.Join(directoryPath, info.Name()),
+		}
+
+		if indexEntry, ok := index[info.Name()]; ok {
+			entry.CacheIndexEntry = *indexEntry
+		} else {
+			entry.Source = "unknown"
+			entry.Tag = info.Name()
+			entry.DownloadedAt = info.ModTime()
+			entry.LastUsedAt = info.ModTime()
+		}
+
+		entry.Size, err = files.DirectorySize(entry.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].LastUsedAt.Equal(entries[j].LastUsedAt) {
+			return entries[i].Name < entries[j].Name
+		}
+
+		return entries[i].LastUsedAt.After(entries[j].LastUsedAt)
+	})
+
+	return entries, nil
+}
+
+// PruneCache deletes all but the keep most recently used entries in directoryPath.
+// Entries whose path is in protectedPaths, e.g. the manifestsRepo of config.yaml, are never deleted.
+// If dryRun is true, the entries that would be deleted are returned without deleting them.
+func PruneCache(directoryPath string, keep int, protectedPaths []string, dryRun bool) ([]*CacheEntry, error) {
+	if keep < 0 {
+		return nil, fmt.Errorf("keep must be 0 or more, got %v", keep)
+	}
+
+	entries, err := ListCache(directoryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	protected := make(map[string]bool)
+	for _, protectedPath := range protectedPaths {
+		absolutePath, err := filepath.Abs(protectedPath)
+		if err != nil {
+			return nil, err
+		}
+		protected[absolutePath] = true
+	}
+
+	index, err := loadCacheIndex(directoryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	pruned := make([]*CacheEntry, 0)
+	kept := 0
+	for _, entry := range entries {
+		absolutePath, err := filepath.Abs(entry.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		if protected[absolutePath] {
+			continue
+		}
+
+		if kept < keep {
+			kept++
+			continue
+		}
+
+		pruned = append(pruned, entry)
+		if dryRun {
+			continue
+		}
+
+		if err := os.RemoveAll(entry.Path); err != nil {
+			return pruned, err
+		}
+		delete(index, entry.Name)
+	}
+
+	if dryRun || len(pruned) == 0 {
+		return pruned, nil
+	}
+
+	return pruned, writeCacheIndex(directoryPath, index)
+}
@@ -0,0 +1,57 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPruneCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "opctl-manifests-cache")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now().UTC()
+	index := make(map[string]*CacheIndexEntry)
+	for i, name := range []string{"v0.3.0", "v0.2.0", "v0.1.0"} {
+		assert.Nil(t, os.MkdirAll(filepath.Join(dir, name), os.ModePerm))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name, "vars.yaml"), []byte("name: "+name), 0644))
+		usedAt := now.Add(-time.Duration(i) * time.Hour)
+		index[name] = &CacheIndexEntry{Source: SourceGithub, Tag: name, DownloadedAt: usedAt, LastUsedAt: usedAt}
+	}
+	assert.Nil(t, writeCacheIndex(dir, index))
+
+	// The build copy and temporary downloads are not cache entries.
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "cache"), os.ModePerm))
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, ".archive-123"), os.ModePerm))
+
+	entries, err := ListCache(dir)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "v0.3.0", entries[0].Name)
+	assert.Equal(t, SourceGithub, entries[0].Source)
+	assert.Equal(t, int64(len("name: v0.3.0")), entries[0].Size)
+
+	pruned, err := PruneCache(dir, 1, []string{filepath.Join(dir, "v0.1.0")}, true)
+	assert.Nil(t, err)
+	assert.Len(t, pruned, 1)
+	assert.DirExists(t, filepath.Join(dir, "v0.2.0"))
+
+	pruned, err = PruneCache(dir, 1, []string{filepath.Join(dir, "v0.1.0")}, false)
+	assert.Nil(t, err)
+	assert.Len(t, pruned, 1)
+	assert.Equal(t, "v0.2.0", pruned[0].Name)
+
+	entries, err = ListCache(dir)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+
+	index, err = loadCacheIndex(dir)
+	assert.Nil(t, err)
+	_, ok := index["v0.2.0"]
+	assert.False(t, ok)
+}
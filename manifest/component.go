@@ -9,6 +9,7 @@ import (
 type Component struct {
 	path     string
 	overlays []*Overlay
+	metadata *ComponentMetadata
 }
 
 func (c *Component) Path() string {
@@ -27,10 +28,16 @@ func (c *Component) Overlays() []*Overlay {
 	return c.overlays
 }
 
+// Metadata returns the dependencies of the component, see MetadataFileName.
+func (c *Component) Metadata() *ComponentMetadata {
+	return c.metadata
+}
+
 func CreateComponent(path string) *Component {
 	component := &Component{
 		path:     path,
 		overlays: make([]*Overlay, 0),
+		metadata: &ComponentMetadata{},
 	}
 
 	return component
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -52,8 +53,17 @@ func LoadManifest(manifestRoot string) (*Manifest, error) {
 
 		return nil
 	})
+	if err != nil {
+		return m, err
+	}
+
+	for _, component := range m.components {
+		if err := component.loadMetadata(manifestRoot); err != nil {
+			return m, err
+		}
+	}
 
-	return m, err
+	return m, nil
 }
 
 // relative path: something (part of something/base)
@@ -89,6 +99,27 @@ func (m *Manifest) addOverlay(relativePath string) *Overlay {
 	return overlay
 }
 
+// Components returns the components of the manifest, by path.
+func (m *Manifest) Components() []*Component {
+	paths := make([]string, 0, len(m.components))
+	for path := range m.components {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	components := make([]*Component, 0, len(paths))
+	for _, path := range paths {
+		components = append(components, m.components[path])
+	}
+
+	return components
+}
+
+// Path returns the directory the manifest was loaded from.
+func (m *Manifest) Path() string {
+	return m.path
+}
+
 func (m *Manifest) GetComponent(path string) *Component {
 	return m.components[path]
 }
@@ -0,0 +1,88 @@
+package manifest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/onepanelio/cli/files"
+	"gopkg.in/yaml.v2"
+)
+
+// MetadataFileName is the metadata file of a component, next to its base directory, e.g. cert-manager/component.yaml.
+const MetadataFileName = "component.yaml"
+
+// ComponentMetadata declares how a component depends on the rest of the manifests.
+type ComponentMetadata struct {
+	// Requires are the paths of the components added along with this one, e.g. istio for argo.
+	Requires []string `yaml:"requires,omitempty"`
+	// Conflicts are the paths of the components that can't be added along with this one.
+	Conflicts []string `yaml:"conflicts,omitempty"`
+	// Providers are the providers the component can be added for, e.g. minikube and microk8s for metallb. Any provider if empty.
+	Providers []string `yaml:"providers,omitempty"`
+	// RequiresOverlays are the overlay contenders that have to be set for the component, e.g. https for cert-manager.
+	RequiresOverlays []string `yaml:"requiresOverlays,omitempty"`
+	// ExclusiveOverlays are groups of overlays of the component that can't be selected together, on top of DefaultExclusiveOverlays.
+	ExclusiveOverlays [][]string `yaml:"exclusiveOverlays,omitempty"`
+}
+
+// DefaultExclusiveOverlays are the groups of overlays of any component that can't be selected together:
+// the overlays of the providers, and of the dns providers.
+var DefaultExclusiveOverlays = [][]string{
+	{"aks", "eks", "gke", "minikube", "microk8s"},
+	{"azuredns", "clouddns", "cloudflare", "route53"},
+}
+
+// DefaultComponentMetadata is the metadata of the components of manifests released without metadata files.
+var DefaultComponentMetadata = map[string]ComponentMetadata{
+	"cert-manager": {
+		Providers:        []string{"aks", "eks", "gke"},
+		RequiresOverlays: []string{"https"},
+	},
+	"metallb": {
+		Providers: []string{"minikube", "microk8s"},
+	},
+}
+
+// loadMetadata reads the metadata file of the component, if there is one, or uses DefaultComponentMetadata.
+func (c *Component) loadMetadata(manifestRoot string) error {
+	path := filepath.Join(manifestRoot, c.path, MetadataFileName)
+	exists, err := files.Exists(path)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		metadata := DefaultComponentMetadata[filepath.ToSlash(c.path)]
+		c.metadata = &metadata
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	metadata := &ComponentMetadata{}
+	if err := yaml.UnmarshalStrict(data, metadata); err != nil {
+		return fmt.Errorf("unable to read %v: %v", path, err.Error())
+	}
+	c.metadata = metadata
+
+	return nil
+}
+
+// SupportsProvider is true if the component can be added for provider.
+func (m *ComponentMetadata) SupportsProvider(provider string) bool {
+	if len(m.Providers) == 0 || provider == "" {
+		return true
+	}
+
+	for _, supported := range m.Providers {
+		if supported == provider {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,66 @@
+package manifest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SortTopologically orders names so each one comes after the names it depends on.
+// Names that don't depend on each other are ordered alphabetically, so the result is the same on every run.
+// Dependencies that are not in names are ignored.
+func SortTopologically(names []string, dependencies func(name string) []string) ([]string, error) {
+	included := make(map[string]bool)
+	for _, name := range names {
+		included[name] = true
+	}
+
+	dependents := make(map[string][]string)
+	remaining := make(map[string]int)
+	for name := range included {
+		remaining[name] = 0
+		for _, dependency := range dependencies(name) {
+			if !included[dependency] || dependency == name {
+				continue
+			}
+			dependents[dependency] = append(dependents[dependency], name)
+			remaining[name]++
+		}
+	}
+
+	ready := make([]string, 0)
+	for name, count := range remaining {
+		if count == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	result := make([]string, 0, len(included))
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		name := ready[0]
+		ready = ready[1:]
+		result = append(result, name)
+
+		for _, dependent := range dependents[name] {
+			remaining[dependent]--
+			if remaining[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(result) != len(included) {
+		cycle := make([]string, 0)
+		for name, count := range remaining {
+			if count > 0 {
+				cycle = append(cycle, name)
+			}
+		}
+		sort.Strings(cycle)
+
+		return nil, fmt.Errorf("dependency cycle between %v", strings.Join(cycle, ", "))
+	}
+
+	return result, nil
+}
@@ -3,6 +3,7 @@ package manifest
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 )
 
 type Overlay struct {
@@ -21,6 +22,11 @@ func (v *Overlay) Path() string {
 	return v.path
 }
 
+// Name returns the name of the overlay, the overlay contender that selects it, e.g. gcp for istio/overlays/gcp.
+func (v *Overlay) Name() string {
+	return filepath.Base(v.path)
+}
+
 func (v *Overlay) Component() *Component {
 	return v.component
 }
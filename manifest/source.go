@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"github.com/onepanelio/cli/files"
 	"github.com/onepanelio/cli/github"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -20,8 +22,26 @@ const (
 	//  directory:
 	// This indicates manifests should be retrieved from some local directory.
 	SourceDirectory = "directory"
+	// SourceGit refers to cli_config.yaml value,
+	// manifestSource:
+	//  git:
+	// This indicates manifests should be cloned from a git repository.
+	SourceGit = "git"
+	// SourceArchive refers to cli_config.yaml value,
+	// manifestSource:
+	//  archive:
+	// This indicates manifests should be downloaded from an archive pinned by its sha256 checksum.
+	SourceArchive = "archive"
+	// SourceOCI refers to cli_config.yaml value,
+	// manifestSource:
+	//  oci:
+	// This indicates manifests should be pulled from an OCI registry.
+	SourceOCI = "oci"
 )
 
+// DefaultManifestsRepository is the github repository the manifests are downloaded from.
+const DefaultManifestsRepository = "onepanelio/manifests"
+
 type Source interface {
 	MoveToDirectory(destinationPath string) error
 	// Get the resulting manifest path. Should only be called after MoveToDirectory
@@ -30,10 +50,21 @@ type Source interface {
 	GetSourceType() string
 }
 
+// signedSource is a Source that can verify the signature of the bundle it downloads.
+// Once a verifier is set, the cache is not used so every bundle is verified.
+type signedSource interface {
+	setVerifier(verifier *Verifier)
+}
+
 type GithubSource struct {
 	tag           string // The tag of the release. latest is also accepted.
 	overrideCache bool   // if true, will override the local cached files.
+	apiUrl        string // github API url, for GitHub Enterprise. If empty, github.com is used
+	repository    string // owner/name of the repository. If empty, DefaultManifestsRepository
+	token         string // token for the API. If empty, GITHUB_TOKEN is used
+	api           *github.Github
 	release       *github.Release
+	verifier      *Verifier
 	moved         bool   // true if MoveToDirectory has been called
 	destination   string // the directory to move the manifest files to
 }
@@ -53,14 +84,48 @@ func (g *GithubSource) GetSourceType() string {
 	return SourceGithub
 }
 
-// GetTag returns the ManifestsRepositoryTag set in the CLI via build flag.
+// GetTag returns the tag from cli_config.yaml. Once the release is resolved,
+// the tag of the release, e.g. the version a constraint like ~0.12 resolved to.
 func (g *GithubSource) GetTag() string {
+	if g.release != nil {
+		return g.release.TagName
+	}
+
 	return g.tag
 }
 
+func (g *GithubSource) setVerifier(verifier *Verifier) {
+	g.verifier = verifier
+}
+
+// GithubApi returns the client for the repository of the source.
+func (g *GithubSource) GithubApi() (*github.Github, error) {
+	if g.api != nil {
+		return g.api, nil
+	}
+
+	repository := g.repository
+	if repository == "" {
+		repository = DefaultManifestsRepository
+	}
+
+	token := g.token
+	if token == "" {
+		token = os.Getenv(github.TokenEnv)
+	}
+
+	api, err := github.NewWithToken(github.RepositoryUrl(g.apiUrl, repository), token)
+	if err != nil {
+		return nil, err
+	}
+	g.api = api
+
+	return api, nil
+}
+
 func (g *GithubSource) getTagDownloadUrl() (string, error) {
 	if g.release == nil {
-		githubApi, err := github.New("https://api.github.com/repos/onepanelio/manifests")
+		githubApi, err := g.GithubApi()
 		if err != nil {
 			return "", err
 		}
@@ -72,6 +137,16 @@ func (g *GithubSource) getTagDownloadUrl() (string, error) {
 			if err != nil {
 				return "", err
 			}
+		} else if IsVersionConstraint(g.tag) {
+			releases, err := githubApi.ListReleases(false)
+			if err != nil {
+				return "", err
+			}
+
+			release, err = ResolveVersionConstraint(g.tag, releases)
+			if err != nil {
+				return "", err
+			}
 		} else {
 			release, err = githubApi.GetReleaseByTag(g.tag)
 			if err != nil {
@@ -114,6 +189,10 @@ func (g *GithubSource) MoveToDirectory(directoryPath string) error {
 		return err
 	}
 
+	if err := CheckCompatibility(g.release.TagName); err != nil {
+		return err
+	}
+
 	finalManifestPath := g.getManifestPath(directoryPath)
 
 	cacheExists, err := files.Exists(finalManifestPath)
@@ -121,7 +200,17 @@ func (g *GithubSource) MoveToDirectory(directoryPath string) error {
 		return err
 	}
 
-	if !g.overrideCache && cacheExists {
+	if !g.overrideCache && g.verifier == nil && cacheExists {
+		g.moved = true
+		return nil
+	}
+
+	// The cached manifests are only replaced once the signature of the release is verified.
+	if g.verifier != nil {
+		if err := g.moveSignedRelease(directoryPath, finalManifestPath); err != nil {
+			return err
+		}
+
 		g.moved = true
 		return nil
 	}
@@ -130,7 +219,7 @@ func (g *GithubSource) MoveToDirectory(directoryPath string) error {
 		return err
 	}
 
-	if err := files.DownloadFile(tempManifestsPath, sourceUrl); err != nil {
+	if err := g.api.DownloadFile(tempManifestsPath, sourceUrl); err != nil {
 		log.Printf("[error] Downloading %v: error %v", sourceUrl, err.Error())
 		return err
 	}
@@ -227,3 +316,85 @@ func (d *DirectorySource) MoveToDirectory(directoryPath string) error {
 
 	return err
 }
+
+// moveSignedRelease downloads the manifests archive attached to the release, verifies its signature
+// and replaces finalManifestPath with the manifests. The archive source code zip of the release
+// is generated by github, so it can't be signed.
+func (g *GithubSource) moveSignedRelease(directoryPath, finalManifestPath string) error {
+	var archive, signature *github.Asset
+	for _, name := range []string{"manifests.tar.gz", "manifests.zip"} {
+		archive = g.release.FindAsset(name)
+		if archive == nil {
+			continue
+		}
+
+		signature = g.release.FindAsset(name + ".minisig")
+		if signature == nil {
+			signature = g.release.FindAsset(name + ".sig")
+		}
+		break
+	}
+
+	if archive == nil || signature == nil {
+		return fmt.Errorf("verification is enabled, but release %v has no signed manifests.tar.gz or manifests.zip asset", g.release.TagName)
+	}
+
+	if err := os.MkdirAll(directoryPath, os.ModePerm); err != nil {
+		return err
+	}
+
+	tempPath, err := ioutil.TempDir(directoryPath, ".github-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(tempPath); err != nil {
+			log.Printf("[error] Deleting %v: %v", tempPath, err.Error())
+		}
+	}()
+
+	archivePath := filepath.Join(tempPath, archive.Name)
+	if err := g.downloadAsset(archive, archivePath); err != nil {
+		return err
+	}
+
+	signaturePath := filepath.Join(tempPath, signature.Name)
+	if err := g.downloadAsset(signature, signaturePath); err != nil {
+		return err
+	}
+
+	signatureData, err := ioutil.ReadFile(signaturePath)
+	if err != nil {
+		return err
+	}
+
+	if err := g.verifier.VerifyFile(archivePath, signatureData); err != nil {
+		return fmt.Errorf("verifying release %v: %v", g.release.TagName, err.Error())
+	}
+
+	extractedPath := filepath.Join(tempPath, "extracted")
+	if err := extractArchive(archivePath, extractedPath); err != nil {
+		return err
+	}
+
+	rootPath, err := archiveRoot(extractedPath)
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(finalManifestPath); err != nil {
+		return err
+	}
+
+	return os.Rename(rootPath, finalManifestPath)
+}
+
+// downloadAsset downloads a release asset through the API, which also works for private repositories.
+func (g *GithubSource) downloadAsset(asset *github.Asset, path string) error {
+	url := asset.Url
+	if url == "" {
+		url = asset.BrowserDownloadUrl
+	}
+
+	return g.api.DownloadFile(path, url)
+}
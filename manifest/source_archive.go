@@ -0,0 +1,274 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/onepanelio/cli/files"
+)
+
+// ArchiveSource downloads the manifests as a .zip or .tar.gz archive from a url, e.g. an internal artifact server.
+// The archive is only extracted if its sha256 checksum matches the pinned one.
+type ArchiveSource struct {
+	url           string // http, https or file url of the archive
+	sha256        string // expected hex encoded sha256 checksum of the archive
+	signatureUrl  string // url of the detached signature. If empty, the url with .minisig or .sig appended
+	verifier      *Verifier
+	overrideCache bool   // if true, will override the local cached files.
+	moved         bool   // true if MoveToDirectory has been called
+	destination   string // the directory to move the manifest files to
+}
+
+func CreateArchiveSource(archiveUrl, checksum string, overrideCache bool) (*ArchiveSource, error) {
+	if archiveUrl == "" {
+		return nil, fmt.Errorf("archive source is missing the url")
+	}
+
+	checksum = strings.ToLower(strings.TrimPrefix(checksum, "sha256:"))
+	if len(checksum) != sha256.Size*2 {
+		return nil, fmt.Errorf("archive source requires the sha256 checksum of %v", archiveUrl)
+	}
+
+	if archiveExtension(archiveUrl) == "" {
+		return nil, fmt.Errorf("archive %v must be a .zip, .tar.gz or .tgz file", archiveUrl)
+	}
+
+	source := &ArchiveSource{
+		url:           archiveUrl,
+		sha256:        checksum,
+		overrideCache: overrideCache,
+		moved:         false,
+	}
+
+	return source, nil
+}
+
+// GetSourceType returns the string name of ArchiveSource.
+func (a *ArchiveSource) GetSourceType() string {
+	return SourceArchive
+}
+
+// GetTag returns the sha256 checksum of the archive, which identifies the manifests.
+func (a *ArchiveSource) GetTag() string {
+	return a.sha256
+}
+
+func (a *ArchiveSource) setVerifier(verifier *Verifier) {
+	a.verifier = verifier
+}
+
+func (a *ArchiveSource) getManifestPath(directoryPath string) string {
+	return filepath.Join(directoryPath, "sha256-"+a.sha256[:12])
+}
+
+func (a *ArchiveSource) GetManifestPath() (string, error) {
+	if !a.moved {
+		return "", fmt.Errorf("files not yet moved. Unable to get manifest path")
+	}
+
+	return a.getManifestPath(a.destination), nil
+}
+
+func (a *ArchiveSource) MoveToDirectory(directoryPath string) error {
+	a.destination = directoryPath
+
+	finalManifestPath := a.getManifestPath(directoryPath)
+
+	cacheExists, err := files.Exists(finalManifestPath)
+	if err != nil {
+		return err
+	}
+
+	if !a.overrideCache && a.verifier == nil && cacheExists {
+		a.moved = true
+		return nil
+	}
+
+	if err := os.MkdirAll(directoryPath, os.ModePerm); err != nil {
+		return err
+	}
+
+	tempPath, err := ioutil.TempDir(directoryPath, ".archive-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(tempPath); err != nil {
+			log.Printf("[error] Deleting %v: %v", tempPath, err.Error())
+		}
+	}()
+
+	archivePath := filepath.Join(tempPath, "manifests"+archiveExtension(a.url))
+	if err := a.download(archivePath); err != nil {
+		return err
+	}
+
+	if a.verifier != nil {
+		if err := a.verify(archivePath); err != nil {
+			return err
+		}
+	}
+
+	extractedPath := filepath.Join(tempPath, "extracted")
+	if err := extractArchive(archivePath, extractedPath); err != nil {
+		return err
+	}
+
+	rootPath, err := archiveRoot(extractedPath)
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(finalManifestPath); err != nil {
+		return err
+	}
+
+	if err := os.Rename(rootPath, finalManifestPath); err != nil {
+		return err
+	}
+
+	a.moved = true
+
+	return nil
+}
+
+// download copies the archive to path and verifies its checksum.
+func (a *ArchiveSource) download(path string) error {
+	reader, err := openArchiveUrl(a.url)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, hash), reader); err != nil {
+		return err
+	}
+
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if actual != a.sha256 {
+		return fmt.Errorf("checksum mismatch for %v: expected sha256 %v, got %v", a.url, a.sha256, actual)
+	}
+
+	return nil
+}
+
+// verify checks the detached signature of the archive at path.
+func (a *ArchiveSource) verify(path string) error {
+	signatureUrls := []string{a.signatureUrl}
+	if a.signatureUrl == "" {
+		signatureUrls = []string{a.url + ".minisig", a.url + ".sig"}
+	}
+
+	var signature []byte
+	var err error
+	for _, signatureUrl := range signatureUrls {
+		signature, err = readSignature(signatureUrl)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("verification is enabled, but the signature of %v could not be downloaded: %v", a.url, err.Error())
+	}
+
+	if err := a.verifier.VerifyFile(path, signature); err != nil {
+		return fmt.Errorf("verifying %v: %v", a.url, err.Error())
+	}
+
+	return nil
+}
+
+// readSignature reads a detached signature, which are small, so at most 64KiB are read.
+func readSignature(signatureUrl string) ([]byte, error) {
+	reader, err := openArchiveUrl(signatureUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ioutil.ReadAll(io.LimitReader(reader, 64*1024))
+}
+
+func openArchiveUrl(archiveUrl string) (io.ReadCloser, error) {
+	parsedUrl, err := url.Parse(archiveUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parsedUrl.Scheme {
+	case "http", "https":
+		response, err := http.Get(archiveUrl)
+		if err != nil {
+			return nil, err
+		}
+
+		if response.StatusCode < 200 || response.StatusCode > 299 {
+			response.Body.Close()
+			return nil, fmt.Errorf("downloading %v: unexpected status %v", archiveUrl, response.Status)
+		}
+
+		return response.Body, nil
+	case "file":
+		return os.Open(filepath.FromSlash(parsedUrl.Path))
+	case "":
+		return os.Open(archiveUrl)
+	}
+
+	return nil, fmt.Errorf("unsupported url scheme '%v' in %v", parsedUrl.Scheme, archiveUrl)
+}
+
+func archiveExtension(archiveUrl string) string {
+	path := archiveUrl
+	if parsedUrl, err := url.Parse(archiveUrl); err == nil {
+		path = parsedUrl.Path
+	}
+
+	for _, extension := range []string{".zip", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(path, extension) {
+			return extension
+		}
+	}
+
+	return ""
+}
+
+func extractArchive(archivePath, destination string) error {
+	if strings.HasSuffix(archivePath, ".zip") {
+		_, err := files.Unzip(archivePath, destination)
+		return err
+	}
+
+	_, err := files.Untar(archivePath, destination)
+	return err
+}
+
+// archiveRoot returns the directory holding the manifests. Archives made by github and most tools
+// wrap the files in a single top level directory, which is skipped.
+func archiveRoot(extractedPath string) (string, error) {
+	entries, err := ioutil.ReadDir(extractedPath)
+	if err != nil {
+		return "", err
+	}
+
+	if len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(extractedPath, entries[0].Name()), nil
+	}
+
+	return extractedPath, nil
+}
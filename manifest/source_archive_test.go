@@ -0,0 +1,108 @@
+package manifest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createTestTarGz returns a .tar.gz archive with files wrapped in a single top level directory.
+func createTestTarGz(t *testing.T, files map[string]string) []byte {
+	buffer := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	assert.Nil(t, tarWriter.WriteHeader(&tar.Header{Name: "manifests-1.0/", Typeflag: tar.TypeDir, Mode: 0755}))
+	for name, content := range files {
+		assert.Nil(t, tarWriter.WriteHeader(&tar.Header{Name: "manifests-1.0/" + name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+		_, err := tarWriter.Write([]byte(content))
+		assert.Nil(t, err)
+	}
+
+	assert.Nil(t, tarWriter.Close())
+	assert.Nil(t, gzipWriter.Close())
+
+	return buffer.Bytes()
+}
+
+func TestArchiveSource_MoveToDirectory(t *testing.T) {
+	archive := createTestTarGz(t, map[string]string{"istio/base/vars.yaml": "istio: {}\n"})
+	hash := sha256.Sum256(archive)
+	checksum := hex.EncodeToString(hash[:])
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "opctl-archive-source")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	source, err := CreateArchiveSource(server.URL+"/manifests.tar.gz", checksum, false)
+	assert.Nil(t, err)
+	assert.Nil(t, source.MoveToDirectory(dir))
+	assert.Equal(t, checksum, source.GetTag())
+
+	manifestPath, err := source.GetManifestPath()
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "sha256-"+checksum[:12]), manifestPath)
+	assert.FileExists(t, filepath.Join(manifestPath, "istio", "base", "vars.yaml"))
+
+	// The cached copy is used the second time.
+	source, err = CreateArchiveSource(server.URL+"/manifests.tar.gz", checksum, false)
+	assert.Nil(t, err)
+	assert.Nil(t, source.MoveToDirectory(dir))
+	assert.Equal(t, 1, requests)
+
+	entries, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestArchiveSource_ChecksumMismatch(t *testing.T) {
+	archive := createTestTarGz(t, map[string]string{"istio/base/vars.yaml": "istio: {}\n"})
+
+	dir, err := ioutil.TempDir("", "opctl-archive-source")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	archivePath := filepath.Join(dir, "manifests.tgz")
+	assert.Nil(t, ioutil.WriteFile(archivePath, archive, 0644))
+
+	wrongChecksum := hex.EncodeToString(make([]byte, sha256.Size))
+	source, err := CreateArchiveSource("file://"+archivePath, wrongChecksum, false)
+	assert.Nil(t, err)
+
+	destination := filepath.Join(dir, "manifests")
+	assert.NotNil(t, source.MoveToDirectory(destination))
+
+	entries, err := ioutil.ReadDir(destination)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 0)
+}
+
+func TestCreateArchiveSource_Validation(t *testing.T) {
+	checksum := hex.EncodeToString(make([]byte, sha256.Size))
+
+	_, err := CreateArchiveSource("https://example.com/manifests.zip", "", false)
+	assert.NotNil(t, err)
+
+	_, err = CreateArchiveSource("https://example.com/manifests.rar", checksum, false)
+	assert.NotNil(t, err)
+
+	_, err = CreateArchiveSource("https://example.com/manifests.zip", "sha256:"+checksum, false)
+	assert.Nil(t, err)
+}
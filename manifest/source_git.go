@@ -0,0 +1,219 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/onepanelio/cli/files"
+)
+
+var commitShaRegex = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// GitSource clones the manifests from any git repository the git binary can reach,
+// e.g. a fork on github, a file:// url or a local bare repository.
+// The manifests are cached by the commit sha the ref resolves to.
+type GitSource struct {
+	url           string // url of the repository
+	ref           string // branch, tag or commit. If empty, the default branch is used.
+	overrideCache bool   // if true, will override the local cached files.
+	sha           string // the commit ref resolved to. Set by MoveToDirectory
+	moved         bool   // true if MoveToDirectory has been called
+	destination   string // the directory to move the manifest files to
+}
+
+func CreateGitSource(url, ref string, overrideCache bool) (*GitSource, error) {
+	if url == "" {
+		return nil, fmt.Errorf("git source is missing the url")
+	}
+
+	source := &GitSource{
+		url:           url,
+		ref:           ref,
+		overrideCache: overrideCache,
+		moved:         false,
+	}
+
+	return source, nil
+}
+
+// GetSourceType returns the string name of GitSource.
+func (g *GitSource) GetSourceType() string {
+	return SourceGit
+}
+
+// GetTag returns the commit sha the ref resolved to.
+// It is empty until MoveToDirectory has been called.
+func (g *GitSource) GetTag() string {
+	return g.sha
+}
+
+func (g *GitSource) GetManifestPath() (string, error) {
+	if !g.moved {
+		return "", fmt.Errorf("files not yet moved. Unable to get manifest path")
+	}
+
+	return filepath.Join(g.destination, g.sha), nil
+}
+
+func (g *GitSource) MoveToDirectory(directoryPath string) error {
+	g.destination = directoryPath
+
+	// Try to find the commit without cloning, so cached manifests don't need a clone.
+	sha, err := g.resolveRemoteRef()
+	if err != nil {
+		return err
+	}
+
+	if sha != "" && !g.overrideCache {
+		cacheExists, err := files.Exists(filepath.Join(directoryPath, sha))
+		if err != nil {
+			return err
+		}
+
+		if cacheExists {
+			g.sha = sha
+			g.moved = true
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(directoryPath, os.ModePerm); err != nil {
+		return err
+	}
+
+	// Clone next to the final directory so it can be renamed into place.
+	clonePath, err := ioutil.TempDir(directoryPath, ".git-clone-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(clonePath); err != nil {
+			log.Printf("[error] Deleting %v: %v", clonePath, err.Error())
+		}
+	}()
+
+	if _, err := runGit("", "clone", "--quiet", "--no-checkout", g.url, clonePath); err != nil {
+		return err
+	}
+
+	sha, err = g.resolveLocalRef(clonePath)
+	if err != nil {
+		return err
+	}
+
+	if _, err := runGit(clonePath, "checkout", "--quiet", "--detach", sha); err != nil {
+		return err
+	}
+
+	finalManifestPath := filepath.Join(directoryPath, sha)
+
+	cacheExists, err := files.Exists(finalManifestPath)
+	if err != nil {
+		return err
+	}
+
+	if !g.overrideCache && cacheExists {
+		g.sha = sha
+		g.moved = true
+		return nil
+	}
+
+	if err := os.RemoveAll(finalManifestPath); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(filepath.Join(clonePath, ".git")); err != nil {
+		return err
+	}
+
+	if err := os.Rename(clonePath, finalManifestPath); err != nil {
+		return err
+	}
+
+	g.sha = sha
+	g.moved = true
+
+	return nil
+}
+
+// resolveRemoteRef looks up the commit of a branch or tag with git ls-remote.
+// An empty sha is returned if the ref can only be resolved from a clone, e.g. an abbreviated commit.
+func (g *GitSource) resolveRemoteRef() (string, error) {
+	if commitShaRegex.MatchString(g.ref) {
+		return g.ref, nil
+	}
+
+	// ls-remote matches patterns against the end of ref names, e.g. master also matches refs/heads/feature/master,
+	// so the exact names are queried and compared. Like git, tags take precedence over branches.
+	// Annotated tags point to a tag object, the peeled ^{} entry is the commit.
+	names := []string{"HEAD"}
+	if g.ref != "" {
+		names = []string{"refs/tags/" + g.ref + "^{}", "refs/tags/" + g.ref, "refs/heads/" + g.ref}
+		if strings.HasPrefix(g.ref, "refs/") {
+			names = append([]string{g.ref + "^{}", g.ref}, names...)
+		}
+	}
+
+	output, err := runGit("", append([]string{"ls-remote", g.url}, names...)...)
+	if err != nil {
+		return "", err
+	}
+
+	shas := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		shas[fields[1]] = fields[0]
+	}
+
+	for _, name := range names {
+		if sha, ok := shas[name]; ok {
+			return sha, nil
+		}
+	}
+
+	return "", nil
+}
+
+// resolveLocalRef finds the commit of the ref in the clone at clonePath.
+func (g *GitSource) resolveLocalRef(clonePath string) (string, error) {
+	if g.ref == "" {
+		return runGit(clonePath, "rev-parse", "--verify", "HEAD^{commit}")
+	}
+
+	for _, candidate := range []string{g.ref, "origin/" + g.ref} {
+		sha, err := runGit(clonePath, "rev-parse", "--verify", "--quiet", candidate+"^{commit}")
+		if err == nil && sha != "" {
+			return sha, nil
+		}
+	}
+
+	return "", fmt.Errorf("unable to find ref '%v' in %v", g.ref, g.url)
+}
+
+// runGit runs git with args in dir and returns the trimmed standard output.
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %v: %v %v", args[0], err.Error(), strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
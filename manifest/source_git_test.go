@@ -0,0 +1,109 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createTestGitRepository creates a bare repository with two commits on master,
+// a lightweight tag on the first one and an annotated tag on the second one.
+// The feature/master branch points to the first commit, its name also ends with master.
+func createTestGitRepository(t *testing.T, dir string) (bareUrl string, firstSha string, secondSha string) {
+	workPath := filepath.Join(dir, "work")
+	barePath := filepath.Join(dir, "manifests.git")
+
+	git := func(args ...string) string {
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		output, err := runGit(workPath, args...)
+		assert.Nil(t, err)
+		return output
+	}
+
+	assert.Nil(t, os.MkdirAll(filepath.Join(workPath, "istio", "base"), os.ModePerm))
+	git("init", "--quiet")
+	git("checkout", "--quiet", "-b", "master")
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(workPath, "istio", "base", "vars.yaml"), []byte("istio: {}\n"), 0644))
+	git("add", ".")
+	git("commit", "--quiet", "-m", "first")
+	git("tag", "v0.1.0")
+	git("branch", "feature/master")
+	firstSha = git("rev-parse", "HEAD")
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(workPath, "istio", "base", "vars.yaml"), []byte("istio: {enabled: true}\n"), 0644))
+	git("commit", "--quiet", "-am", "second")
+	git("tag", "-a", "v0.2.0", "-m", "release")
+	secondSha = git("rev-parse", "HEAD")
+
+	_, err := runGit(dir, "clone", "--quiet", "--bare", workPath, barePath)
+	assert.Nil(t, err)
+
+	return "file://" + barePath, firstSha, secondSha
+}
+
+func TestGitSource_MoveToDirectory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "opctl-git-source")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	url, firstSha, secondSha := createTestGitRepository(t, dir)
+	destination := filepath.Join(dir, "manifests")
+
+	tests := []struct {
+		ref string
+		sha string
+	}{
+		{ref: "", sha: secondSha},
+		{ref: "v0.1.0", sha: firstSha},
+		// The first commit is cached now, so a wrong remote ref would resolve to it without a clone.
+		{ref: "master", sha: secondSha},
+		{ref: "feature/master", sha: firstSha},
+		{ref: "refs/heads/master", sha: secondSha},
+		{ref: "v0.2.0", sha: secondSha},
+		{ref: firstSha, sha: firstSha},
+		{ref: firstSha[:8], sha: firstSha},
+	}
+
+	for _, test := range tests {
+		source, err := CreateGitSource(url, test.ref, false)
+		assert.Nil(t, err)
+		assert.Nil(t, source.MoveToDirectory(destination), test.ref)
+		assert.Equal(t, test.sha, source.GetTag(), test.ref)
+
+		manifestPath, err := source.GetManifestPath()
+		assert.Nil(t, err)
+		assert.Equal(t, filepath.Join(destination, test.sha), manifestPath)
+		assert.FileExists(t, filepath.Join(manifestPath, "istio", "base", "vars.yaml"))
+		_, err = os.Stat(filepath.Join(manifestPath, ".git"))
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	entries, err := ioutil.ReadDir(destination)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestGitSource_UnknownRef(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "opctl-git-source")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	url, _, _ := createTestGitRepository(t, dir)
+
+	source, err := CreateGitSource(url, "does-not-exist", false)
+	assert.Nil(t, err)
+	assert.NotNil(t, source.MoveToDirectory(filepath.Join(dir, "manifests")))
+}
@@ -12,16 +12,23 @@ import (
 
 type SourceConfig struct {
 	ManifestSourceConfig ManifestSourceConfig `yaml:"manifestSource"`
+	Verification         *VerificationConfig  `yaml:"verification,omitempty"`
 }
 
 type ManifestSourceConfig struct {
 	Github    *GithubSourceConfig    `yaml:"github,omitempty"`
 	Directory *DirectorySourceConfig `yaml:"directory,omitempty"`
+	Git       *GitSourceConfig       `yaml:"git,omitempty"`
+	Archive   *ArchiveSourceConfig   `yaml:"archive,omitempty"`
+	OCI       *OCISourceConfig       `yaml:"oci,omitempty"`
 }
 
 type GithubSourceConfig struct {
 	Tag           *string
-	OverrideCache *bool `yaml:"overrideCache,omitempty"` // default is false
+	OverrideCache *bool  `yaml:"overrideCache,omitempty"` // default is false
+	ApiUrl        string `yaml:"apiUrl,omitempty"`        // default is https://api.github.com, e.g. https://github.example.com/api/v3
+	Repository    string `yaml:"repository,omitempty"`    // default is onepanelio/manifests
+	Token         string `yaml:"token,omitempty"`         // default is the GITHUB_TOKEN environment variable
 }
 
 type DirectorySourceConfig struct {
@@ -29,8 +36,32 @@ type DirectorySourceConfig struct {
 	OverrideCache *bool  `yaml:"overrideCache,omitempty"` // default is false
 }
 
-// This will override the file that already exists at path
+type GitSourceConfig struct {
+	URL           string `yaml:"url"`
+	Ref           string `yaml:"ref,omitempty"`           // branch, tag or commit. Default is the default branch
+	OverrideCache *bool  `yaml:"overrideCache,omitempty"` // default is false
+}
+
+type ArchiveSourceConfig struct {
+	URL           string `yaml:"url"`                     // http, https or file url of a .zip, .tar.gz or .tgz file
+	Sha256        string `yaml:"sha256"`                  // required, checksum of the archive
+	SignatureURL  string `yaml:"signatureUrl,omitempty"`  // default is url with .minisig or .sig appended
+	OverrideCache *bool  `yaml:"overrideCache,omitempty"` // default is false
+}
+
+type OCISourceConfig struct {
+	Reference     string `yaml:"reference"`               // e.g. oci://registry.example.com/onepanel/manifests:v0.10.0
+	Insecure      bool   `yaml:"insecure,omitempty"`      // use plain http. Default is false
+	OverrideCache *bool  `yaml:"overrideCache,omitempty"` // default is false
+}
+
+// This will override the file that already exists at path. The verification settings of the existing file are kept.
 func CreateGithubSourceConfigFile(path string) error {
+	var verification *VerificationConfig
+	if existing, err := loadSourceConfig(path); err == nil {
+		verification = existing.Verification
+	}
+
 	_, err := files.DeleteIfExists(path)
 	if err != nil {
 		return err
@@ -45,6 +76,7 @@ func CreateGithubSourceConfigFile(path string) error {
 				OverrideCache: nil,
 			},
 		},
+		Verification: verification,
 	}
 
 	data, err := yaml.Marshal(sourceConfig)
@@ -59,11 +91,54 @@ func CreateGithubSourceConfigFile(path string) error {
 	return err
 }
 
-// Loads and creates the manifest directory in the toPath directory from a config file, configFilePath.
-func LoadManifestSourceFromFileConfig(configFilePath string) (source Source, err error) {
+// UpdateGithubSourceTag sets the tag of the github source in the file at path, keeping the rest of the source.
+func UpdateGithubSourceTag(path string, tag string) error {
+	sourceConfig, err := loadSourceConfig(path)
+	if err != nil {
+		return err
+	}
+
+	if sourceConfig.ManifestSourceConfig.Github == nil {
+		return fmt.Errorf("%v does not use a github source, only the tag of a github source can be updated", path)
+	}
+
+	sourceConfig.ManifestSourceConfig.Github.Tag = &tag
+
+	data, err := yaml.Marshal(sourceConfig)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// CreateDirectorySourceConfigFile overrides the file at path with a directory source that copies the manifests from folder.
+// Directory sources can't be verified, so an error is returned if the existing file has verification enabled.
+func CreateDirectorySourceConfigFile(path string, folder string) error {
+	if existing, err := loadSourceConfig(path); err == nil && existing.Verification != nil && existing.Verification.Enabled {
+		return fmt.Errorf("verification is enabled in %v, directory sources can't be verified", path)
+	}
+
+	sourceConfig := SourceConfig{
+		ManifestSourceConfig: ManifestSourceConfig{
+			Directory: &DirectorySourceConfig{
+				From: folder,
+			},
+		},
+	}
+
+	data, err := yaml.Marshal(sourceConfig)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func loadSourceConfig(configFilePath string) (*SourceConfig, error) {
 	exists, err := files.Exists(configFilePath)
 	if err != nil {
-		return
+		return nil, err
 	}
 
 	if !exists {
@@ -73,22 +148,72 @@ func LoadManifestSourceFromFileConfig(configFilePath string) (source Source, err
 	config := &SourceConfig{}
 	fileData, err := ioutil.ReadFile(configFilePath)
 	if err != nil {
-		return
+		return nil, err
 	}
 
 	if err := yaml.Unmarshal(fileData, config); err != nil {
 		return nil, err
 	}
 
-	if config.ManifestSourceConfig.Github != nil {
-		return loadGithubSource(config.ManifestSourceConfig.Github)
+	return config, nil
+}
+
+// Loads and creates the manifest directory in the toPath directory from a config file, configFilePath.
+// If verification is enabled, the source only accepts bundles signed by one of the trusted keys.
+func LoadManifestSourceFromFileConfig(configFilePath string) (source Source, err error) {
+	config, err := loadSourceConfig(configFilePath)
+	if err != nil {
+		return nil, err
 	}
 
-	if config.ManifestSourceConfig.Directory != nil {
-		return loadDirectorySource(config.ManifestSourceConfig.Directory)
+	source, err = loadSource(&config.ManifestSourceConfig)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil {
+		return nil, fmt.Errorf("%v is badly formatted. No Source Config found", configFilePath)
+	}
+
+	if config.Verification == nil || !config.Verification.Enabled {
+		return source, nil
+	}
+
+	signed, ok := source.(signedSource)
+	if !ok {
+		return nil, fmt.Errorf("verification is enabled, but %v sources can't be verified. Use a github, archive or oci source", source.GetSourceType())
+	}
+
+	verifier, err := NewVerifier(config.Verification.PublicKeys)
+	if err != nil {
+		return nil, err
+	}
+	signed.setVerifier(verifier)
+
+	return source, nil
+}
+
+func loadSource(config *ManifestSourceConfig) (source Source, err error) {
+	if config.Github != nil {
+		return loadGithubSource(config.Github)
+	}
+
+	if config.Directory != nil {
+		return loadDirectorySource(config.Directory)
+	}
+
+	if config.Git != nil {
+		return loadGitSource(config.Git)
+	}
+
+	if config.Archive != nil {
+		return loadArchiveSource(config.Archive)
 	}
 
-	return nil, fmt.Errorf("%v is badly formatted. No Source Config found", configFilePath)
+	if config.OCI != nil {
+		return loadOCISource(config.OCI)
+	}
+
+	return nil, nil
 }
 
 func loadGithubSource(config *GithubSourceConfig) (source Source, err error) {
@@ -102,7 +227,15 @@ func loadGithubSource(config *GithubSourceConfig) (source Source, err error) {
 		config.OverrideCache = &overrideCache
 	}
 
-	return CreateGithubSource(*config.Tag, *config.OverrideCache)
+	githubSource, err := CreateGithubSource(*config.Tag, *config.OverrideCache)
+	if err != nil {
+		return nil, err
+	}
+	githubSource.apiUrl = config.ApiUrl
+	githubSource.repository = config.Repository
+	githubSource.token = config.Token
+
+	return githubSource, nil
 }
 
 func loadDirectorySource(config *DirectorySourceConfig) (source Source, err error) {
@@ -113,3 +246,36 @@ func loadDirectorySource(config *DirectorySourceConfig) (source Source, err erro
 
 	return CreateDirectorySource(config.From, *config.OverrideCache)
 }
+
+func loadGitSource(config *GitSourceConfig) (source Source, err error) {
+	if config.OverrideCache == nil {
+		overrideCache := false
+		config.OverrideCache = &overrideCache
+	}
+
+	return CreateGitSource(config.URL, config.Ref, *config.OverrideCache)
+}
+
+func loadArchiveSource(config *ArchiveSourceConfig) (source Source, err error) {
+	if config.OverrideCache == nil {
+		overrideCache := false
+		config.OverrideCache = &overrideCache
+	}
+
+	archiveSource, err := CreateArchiveSource(config.URL, config.Sha256, *config.OverrideCache)
+	if err != nil {
+		return nil, err
+	}
+	archiveSource.signatureUrl = config.SignatureURL
+
+	return archiveSource, nil
+}
+
+func loadOCISource(config *OCISourceConfig) (source Source, err error) {
+	if config.OverrideCache == nil {
+		overrideCache := false
+		config.OverrideCache = &overrideCache
+	}
+
+	return CreateOCISource(config.Reference, config.Insecure, *config.OverrideCache)
+}
@@ -0,0 +1,161 @@
+package manifest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/onepanelio/cli/files"
+	"github.com/onepanelio/cli/oci"
+)
+
+const (
+	// OCIUsernameEnv and OCIPasswordEnv hold the credentials of private registries.
+	OCIUsernameEnv = "OPCTL_OCI_USERNAME"
+	OCIPasswordEnv = "OPCTL_OCI_PASSWORD"
+)
+
+// OCISource pulls the manifests as an OCI artifact, e.g. oci://registry.example.com/onepanel/manifests:v0.10.0,
+// as pushed by opctl manifests push. The manifests are cached by the digest of the artifact.
+type OCISource struct {
+	reference     *oci.Reference
+	client        *oci.Client
+	overrideCache bool      // if true, will override the local cached files.
+	verifier      *Verifier // if set, the signature of the artifact is verified
+	digest        string    // the digest of the artifact manifest. Set by MoveToDirectory
+	moved         bool      // true if MoveToDirectory has been called
+	destination   string    // the directory to move the manifest files to
+}
+
+// CreateOCISource creates an OCISource. Registry credentials are read from OPCTL_OCI_USERNAME and OPCTL_OCI_PASSWORD.
+// If insecure is true, the registry is accessed over plain http.
+func CreateOCISource(reference string, insecure, overrideCache bool) (*OCISource, error) {
+	ref, err := oci.ParseReference(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &OCISource{
+		reference:     ref,
+		client:        oci.New(os.Getenv(OCIUsernameEnv), os.Getenv(OCIPasswordEnv), insecure),
+		overrideCache: overrideCache,
+		moved:         false,
+	}
+
+	return source, nil
+}
+
+// GetSourceType returns the string name of OCISource.
+func (o *OCISource) GetSourceType() string {
+	return SourceOCI
+}
+
+// GetTag returns the digest of the artifact.
+// It is empty until MoveToDirectory has been called.
+func (o *OCISource) GetTag() string {
+	return o.digest
+}
+
+func (o *OCISource) setVerifier(verifier *Verifier) {
+	o.verifier = verifier
+}
+
+func (o *OCISource) getManifestPath(directoryPath string) string {
+	return filepath.Join(directoryPath, strings.Replace(o.digest, ":", "-", 1))
+}
+
+func (o *OCISource) GetManifestPath() (string, error) {
+	if !o.moved {
+		return "", fmt.Errorf("files not yet moved. Unable to get manifest path")
+	}
+
+	return o.getManifestPath(o.destination), nil
+}
+
+func (o *OCISource) MoveToDirectory(directoryPath string) error {
+	o.destination = directoryPath
+
+	manifest, digest, err := o.client.GetManifest(o.reference)
+	if err != nil {
+		return err
+	}
+	o.digest = digest
+
+	finalManifestPath := o.getManifestPath(directoryPath)
+
+	cacheExists, err := files.Exists(finalManifestPath)
+	if err != nil {
+		return err
+	}
+
+	if !o.overrideCache && o.verifier == nil && cacheExists {
+		o.moved = true
+		return nil
+	}
+
+	layer, err := manifest.ManifestsLayer()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(directoryPath, os.ModePerm); err != nil {
+		return err
+	}
+
+	tempPath, err := ioutil.TempDir(directoryPath, ".oci-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(tempPath); err != nil {
+			log.Printf("[error] Deleting %v: %v", tempPath, err.Error())
+		}
+	}()
+
+	archivePath := filepath.Join(tempPath, "manifests.tar.gz")
+	if err := o.client.DownloadBlob(o.reference, layer, archivePath); err != nil {
+		return err
+	}
+
+	if o.verifier != nil {
+		encodedSignature, ok := manifest.Annotations[oci.SignatureAnnotation]
+		if !ok {
+			return fmt.Errorf("verification is enabled, but %v is not signed", o.reference)
+		}
+
+		signature, err := base64.StdEncoding.DecodeString(encodedSignature)
+		if err != nil {
+			return fmt.Errorf("invalid signature annotation on %v: %v", o.reference, err.Error())
+		}
+
+		if err := o.verifier.VerifyFile(archivePath, signature); err != nil {
+			return fmt.Errorf("verifying %v: %v", o.reference, err.Error())
+		}
+	}
+
+	extractedPath := filepath.Join(tempPath, "extracted")
+	if _, err := files.Untar(archivePath, extractedPath); err != nil {
+		return err
+	}
+
+	rootPath, err := archiveRoot(extractedPath)
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(finalManifestPath); err != nil {
+		return err
+	}
+
+	if err := os.Rename(rootPath, finalManifestPath); err != nil {
+		return err
+	}
+
+	o.moved = true
+
+	return nil
+}
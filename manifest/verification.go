@@ -0,0 +1,229 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// VerificationConfig is the verification section of cli_config.yaml.
+// When enabled, only manifest bundles signed by one of the publicKeys are used.
+type VerificationConfig struct {
+	Enabled    bool     `yaml:"enabled"`
+	PublicKeys []string `yaml:"publicKeys"` // minisign public keys or PEM encoded ed25519/ECDSA keys (cosign)
+}
+
+const (
+	minisignUntrustedComment = "untrusted comment:"
+	minisignTrustedComment   = "trusted comment: "
+)
+
+// minisignPublicKey is a public key as printed by minisign -G.
+type minisignPublicKey struct {
+	keyId [8]byte
+	key   ed25519.PublicKey
+}
+
+// Verifier checks detached signatures of manifest bundles against the trusted keys.
+// Supported are minisign signatures and cosign style signatures, the base64 encoded signature of the bundle.
+type Verifier struct {
+	minisignKeys []minisignPublicKey
+	pemKeys      []interface{} // ed25519.PublicKey or *ecdsa.PublicKey
+}
+
+// NewVerifier parses the trusted keys. Each key is either a minisign public key,
+// optionally with its untrusted comment line, or a PEM encoded public key.
+func NewVerifier(publicKeys []string) (*Verifier, error) {
+	if len(publicKeys) == 0 {
+		return nil, fmt.Errorf("verification is enabled but no publicKeys are configured")
+	}
+
+	verifier := &Verifier{}
+	for i, publicKey := range publicKeys {
+		publicKey = strings.TrimSpace(publicKey)
+
+		if strings.HasPrefix(publicKey, "-----BEGIN") {
+			key, err := parsePemPublicKey(publicKey)
+			if err != nil {
+				return nil, fmt.Errorf("publicKeys[%v]: %v", i, err.Error())
+			}
+			verifier.pemKeys = append(verifier.pemKeys, key)
+			continue
+		}
+
+		key, err := parseMinisignPublicKey(publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("publicKeys[%v]: %v", i, err.Error())
+		}
+		verifier.minisignKeys = append(verifier.minisignKeys, *key)
+	}
+
+	return verifier, nil
+}
+
+func parsePemPublicKey(data string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM public key")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch key.(type) {
+	case ed25519.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unsupported public key type %T, only ed25519 and ECDSA keys are supported", key)
+}
+
+func parseMinisignPublicKey(data string) (*minisignPublicKey, error) {
+	lines := strings.Split(data, "\n")
+	encoded := strings.TrimSpace(lines[len(lines)-1])
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid minisign public key: %v", err.Error())
+	}
+
+	if len(decoded) != 2+8+ed25519.PublicKeySize || string(decoded[:2]) != "Ed" {
+		return nil, fmt.Errorf("invalid minisign public key")
+	}
+
+	key := &minisignPublicKey{key: ed25519.PublicKey(decoded[10:])}
+	copy(key.keyId[:], decoded[2:10])
+
+	return key, nil
+}
+
+// VerifyFile checks the signature of the file at path.
+func (v *Verifier) VerifyFile(path string, signature []byte) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return v.Verify(data, signature)
+}
+
+// Verify checks that signature is a valid signature of data by one of the trusted keys.
+func (v *Verifier) Verify(data, signature []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(signature), []byte(minisignUntrustedComment)) {
+		return v.verifyMinisign(data, string(signature))
+	}
+
+	return v.verifyPem(data, signature)
+}
+
+// verifyMinisign checks a signature created with minisign -S, including the signature of its trusted comment.
+func (v *Verifier) verifyMinisign(data []byte, signature string) error {
+	lines := strings.Split(strings.TrimSpace(signature), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], minisignTrustedComment) {
+		return fmt.Errorf("invalid minisign signature")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(decoded) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("invalid minisign signature")
+	}
+
+	globalSignature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil {
+		return fmt.Errorf("invalid minisign signature")
+	}
+
+	algorithm := string(decoded[:2])
+	message := data
+	switch algorithm {
+	case "Ed":
+	case "ED":
+		// Prehashed signatures, the default since minisign 0.10
+		hash := blake2b.Sum512(data)
+		message = hash[:]
+	default:
+		return fmt.Errorf("unsupported minisign signature algorithm %v", algorithm)
+	}
+
+	for _, key := range v.minisignKeys {
+		if !bytes.Equal(key.keyId[:], decoded[2:10]) {
+			continue
+		}
+
+		if !ed25519.Verify(key.key, message, decoded[10:]) {
+			return fmt.Errorf("signature does not match, the bundle may have been tampered with")
+		}
+
+		trustedComment := strings.TrimPrefix(lines[2], minisignTrustedComment)
+		globalMessage := append(append([]byte{}, decoded[10:]...), trustedComment...)
+		if !ed25519.Verify(key.key, globalMessage, globalSignature) {
+			return fmt.Errorf("the trusted comment of the signature has been tampered with")
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("signature was made with key %X, which is not a trusted key", reverse(decoded[2:10]))
+}
+
+// verifyPem checks a cosign style signature, the base64 encoded signature of data.
+// ECDSA signatures are over the sha256 hash of data, ed25519 signatures are over data.
+func (v *Verifier) verifyPem(data, signature []byte) error {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("invalid signature, expected a minisign or base64 encoded signature")
+	}
+
+	hash := sha256.Sum256(data)
+	for _, key := range v.pemKeys {
+		switch typedKey := key.(type) {
+		case ed25519.PublicKey:
+			if ed25519.Verify(typedKey, data, decoded) {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			if verifyECDSA(typedKey, hash[:], decoded) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("signature does not match any trusted key, the bundle may have been tampered with")
+}
+
+// verifyECDSA checks an ASN.1 encoded ECDSA signature of hash.
+func verifyECDSA(key *ecdsa.PublicKey, hash, signature []byte) bool {
+	values := struct {
+		R, S *big.Int
+	}{}
+
+	rest, err := asn1.Unmarshal(signature, &values)
+	if err != nil || len(rest) != 0 {
+		return false
+	}
+
+	return ecdsa.Verify(key, hash, values.R, values.S)
+}
+
+// reverse returns a reversed copy of data. minisign prints key ids in little endian.
+func reverse(data []byte) []byte {
+	result := make([]byte, len(data))
+	for i := range data {
+		result[len(data)-1-i] = data[i]
+	}
+
+	return result
+}
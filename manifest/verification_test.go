@@ -0,0 +1,235 @@
+package manifest
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/blake2b"
+)
+
+// minisignKeyPair returns a minisign public key and a function that signs like minisign -S.
+func minisignKeyPair(t *testing.T) (string, func(data []byte, prehashed bool, trustedComment string) []byte) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+
+	keyId := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	encodedKey := base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyId...), publicKey...))
+
+	sign := func(data []byte, prehashed bool, trustedComment string) []byte {
+		algorithm := "Ed"
+		if prehashed {
+			algorithm = "ED"
+			hash := blake2b.Sum512(data)
+			data = hash[:]
+		}
+
+		signature := ed25519.Sign(privateKey, data)
+		globalSignature := ed25519.Sign(privateKey, append(append([]byte{}, signature...), trustedComment...))
+
+		return []byte("untrusted comment: signature from minisign secret key\n" +
+			base64.StdEncoding.EncodeToString(append(append([]byte(algorithm), keyId...), signature...)) + "\n" +
+			"trusted comment: " + trustedComment + "\n" +
+			base64.StdEncoding.EncodeToString(globalSignature) + "\n")
+	}
+
+	return "untrusted comment: minisign public key 0807060504030201\n" + encodedKey, sign
+}
+
+func pemPublicKey(t *testing.T, key interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	assert.Nil(t, err)
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func TestVerifier_Minisign(t *testing.T) {
+	publicKey, sign := minisignKeyPair(t)
+	verifier, err := NewVerifier([]string{publicKey})
+	assert.Nil(t, err)
+
+	data := []byte("manifests")
+	assert.Nil(t, verifier.Verify(data, sign(data, false, "timestamp:1")))
+	assert.Nil(t, verifier.Verify(data, sign(data, true, "timestamp:1")))
+	assert.NotNil(t, verifier.Verify([]byte("tampered"), sign(data, true, "timestamp:1")))
+
+	signature := sign(data, true, "timestamp:1")
+	tamperedComment := strings.Replace(string(signature), "timestamp:1", "timestamp:2", 1)
+	assert.NotNil(t, verifier.Verify(data, []byte(tamperedComment)))
+
+	otherPublicKey, _ := minisignKeyPair(t)
+	otherVerifier, err := NewVerifier([]string{otherPublicKey})
+	assert.Nil(t, err)
+	assert.NotNil(t, otherVerifier.Verify(data, signature))
+}
+
+func TestVerifier_Pem(t *testing.T) {
+	data := []byte("manifests")
+
+	edPublicKey, edPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	hash := sha256.Sum256(data)
+	ecdsaSignature, err := ecdsaKey.Sign(rand.Reader, hash[:], nil)
+	assert.Nil(t, err)
+
+	verifier, err := NewVerifier([]string{pemPublicKey(t, edPublicKey), pemPublicKey(t, &ecdsaKey.PublicKey)})
+	assert.Nil(t, err)
+
+	assert.Nil(t, verifier.Verify(data, []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(edPrivateKey, data)))))
+	assert.Nil(t, verifier.Verify(data, []byte(base64.StdEncoding.EncodeToString(ecdsaSignature))))
+	assert.NotNil(t, verifier.Verify([]byte("tampered"), []byte(base64.StdEncoding.EncodeToString(ecdsaSignature))))
+	assert.NotNil(t, verifier.Verify(data, []byte("not a signature")))
+}
+
+func TestArchiveSource_Verification(t *testing.T) {
+	archive := createTestTarGz(t, map[string]string{"istio/base/vars.yaml": "istio: {}\n"})
+	hash := sha256.Sum256(archive)
+	checksum := hex.EncodeToString(hash[:])
+
+	publicKey, sign := minisignKeyPair(t)
+	verifier, err := NewVerifier([]string{publicKey})
+	assert.Nil(t, err)
+
+	signature := sign(archive, true, "manifests")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/manifests.tar.gz":
+			_, _ = w.Write(archive)
+		case "/manifests.tar.gz.minisig":
+			_, _ = w.Write(signature)
+		case "/tampered.tar.gz.sig":
+			_, _ = w.Write(sign([]byte("other"), true, "manifests"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "opctl-archive-source")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	source, err := CreateArchiveSource(server.URL+"/manifests.tar.gz", checksum, false)
+	assert.Nil(t, err)
+	source.setVerifier(verifier)
+	assert.Nil(t, source.MoveToDirectory(dir))
+
+	source, err = CreateArchiveSource(server.URL+"/manifests.tar.gz", checksum, false)
+	assert.Nil(t, err)
+	source.signatureUrl = server.URL + "/tampered.tar.gz.sig"
+	source.setVerifier(verifier)
+	assert.NotNil(t, source.MoveToDirectory(filepath.Join(dir, "tampered")))
+}
+
+func TestLoadManifestSourceFromFileConfig_VerificationUnsupported(t *testing.T) {
+	publicKey, _ := minisignKeyPair(t)
+
+	dir, err := ioutil.TempDir("", "opctl-source-config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	verification := "verification:\n  enabled: true\n  publicKeys:\n  - " + strings.Split(publicKey, "\n")[1] + "\n"
+
+	configPath := filepath.Join(dir, "cli_config.yaml")
+	config := "manifestSource:\n  directory:\n    folder: ../manifests\n" + verification
+	assert.Nil(t, ioutil.WriteFile(configPath, []byte(config), 0644))
+	_, err = LoadManifestSourceFromFileConfig(configPath)
+	assert.NotNil(t, err)
+
+	config = "manifestSource:\n  oci:\n    reference: oci://localhost:5000/onepanel/manifests:v0.1.0\n" + verification
+	assert.Nil(t, ioutil.WriteFile(configPath, []byte(config), 0644))
+	source, err := LoadManifestSourceFromFileConfig(configPath)
+	assert.Nil(t, err)
+	assert.NotNil(t, source.(*OCISource).verifier)
+}
+
+func TestGithubSource_Verification(t *testing.T) {
+	archive := createTestTarGz(t, map[string]string{"istio/base/vars.yaml": "istio: {}\n"})
+
+	publicKey, sign := minisignKeyPair(t)
+	verifier, err := NewVerifier([]string{publicKey})
+	assert.Nil(t, err)
+
+	var serverUrl string
+	signatureTampered := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/onepanelio/manifests/releases/tags/signed":
+			_, _ = w.Write([]byte(`{"tag_name": "signed", "assets": [` +
+				`{"name": "manifests.tar.gz", "url": "` + serverUrl + `/assets/1"},` +
+				`{"name": "manifests.tar.gz.minisig", "url": "` + serverUrl + `/assets/2"}]}`))
+		case "/repos/onepanelio/manifests/releases/tags/tampered":
+			_, _ = w.Write([]byte(`{"tag_name": "tampered", "assets": [` +
+				`{"name": "manifests.tar.gz", "url": "` + serverUrl + `/assets/1"},` +
+				`{"name": "manifests.tar.gz.minisig", "url": "` + serverUrl + `/assets/3"}]}`))
+		case "/assets/1":
+			_, _ = w.Write(archive)
+		case "/assets/2":
+			if signatureTampered {
+				_, _ = w.Write(sign([]byte("other"), true, "manifests"))
+				return
+			}
+			_, _ = w.Write(sign(archive, true, "manifests"))
+		case "/assets/3":
+			_, _ = w.Write(sign([]byte("other"), true, "manifests"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	serverUrl = server.URL
+
+	dir, err := ioutil.TempDir("", "opctl-github-source")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// The manifests directory does not exist yet on a fresh init.
+	manifestsPath := filepath.Join(dir, ".onepanel", "manifests")
+
+	source, err := CreateGithubSource("signed", false)
+	assert.Nil(t, err)
+	source.apiUrl = server.URL
+	source.setVerifier(verifier)
+	assert.Nil(t, source.MoveToDirectory(manifestsPath))
+
+	manifestPath, err := source.GetManifestPath()
+	assert.Nil(t, err)
+	data, err := ioutil.ReadFile(filepath.Join(manifestPath, "istio", "base", "vars.yaml"))
+	assert.Nil(t, err)
+	assert.Equal(t, "istio: {}\n", string(data))
+
+	source, err = CreateGithubSource("tampered", false)
+	assert.Nil(t, err)
+	source.apiUrl = server.URL
+	source.setVerifier(verifier)
+	assert.NotNil(t, source.MoveToDirectory(manifestsPath))
+
+	// A release that fails verification keeps the cached manifests.
+	signatureTampered = true
+	source, err = CreateGithubSource("signed", false)
+	assert.Nil(t, err)
+	source.apiUrl = server.URL
+	source.setVerifier(verifier)
+	assert.NotNil(t, source.MoveToDirectory(manifestsPath))
+
+	data, err = ioutil.ReadFile(filepath.Join(manifestPath, "istio", "base", "vars.yaml"))
+	assert.Nil(t, err)
+	assert.Equal(t, "istio: {}\n", string(data))
+}
@@ -0,0 +1,119 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/onepanelio/cli/config"
+	"github.com/onepanelio/cli/github"
+)
+
+// constraintSeparatorRegex finds the spaces between two constraints, e.g. ">=0.12 <0.13",
+// which the semver library only accepts separated by a comma.
+var constraintSeparatorRegex = regexp.MustCompile(`([0-9xX*])\s+([<>=~^!])`)
+
+// IsVersionConstraint returns true if tag is a semver range, e.g. ~0.12 or >=0.12 <0.13,
+// rather than an exact tag or latest.
+func IsVersionConstraint(tag string) bool {
+	return strings.ContainsAny(tag, "~^<>=*, |")
+}
+
+// ParseVersionConstraint parses a semver range. Constraints can be separated by spaces or commas.
+func ParseVersionConstraint(constraint string) (*semver.Constraints, error) {
+	normalized := constraintSeparatorRegex.ReplaceAllString(strings.TrimSpace(constraint), "$1, $2")
+
+	constraints, err := semver.NewConstraint(normalized)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version constraint '%v': %v", constraint, err.Error())
+	}
+
+	return constraints, nil
+}
+
+// ResolveVersionConstraint returns the release with the highest version that satisfies constraint.
+// Releases whose tags are not versions are ignored.
+func ResolveVersionConstraint(constraint string, releases []*github.Release) (*github.Release, error) {
+	constraints, err := ParseVersionConstraint(constraint)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *github.Release
+	var bestVersion *semver.Version
+	for _, release := range releases {
+		version, err := semver.NewVersion(release.TagName)
+		if err != nil {
+			continue
+		}
+
+		if !constraints.Check(version) {
+			continue
+		}
+
+		if bestVersion == nil || version.GreaterThan(bestVersion) {
+			best = release
+			bestVersion = version
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("no manifests release satisfies '%v'", constraint)
+	}
+
+	return best, nil
+}
+
+// CompatibleVersions returns the range of manifest versions this CLI build supports.
+// It is config.ManifestsCompatibility if set at build time, otherwise the patch releases of config.ManifestsRepositoryTag.
+// Empty means any version, e.g. for development builds.
+func CompatibleVersions() string {
+	if config.ManifestsCompatibility != "" {
+		return config.ManifestsCompatibility
+	}
+
+	version, err := semver.NewVersion(config.ManifestsRepositoryTag)
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("~%v.%v", version.Major(), version.Minor())
+}
+
+// CheckCompatibility returns an error if the manifests tag is outside of CompatibleVersions.
+// Tags that are not versions, e.g. commits or digests, can't be checked and are accepted.
+func CheckCompatibility(tag string) error {
+	compatible := CompatibleVersions()
+	if compatible == "" {
+		return nil
+	}
+
+	version, err := semver.NewVersion(tag)
+	if err != nil {
+		return nil
+	}
+
+	constraints, err := ParseVersionConstraint(compatible)
+	if err != nil {
+		return err
+	}
+
+	if !constraints.Check(version) {
+		return &IncompatibleVersionError{Tag: tag, Compatible: compatible}
+	}
+
+	return nil
+}
+
+// IncompatibleVersionError is returned by CheckCompatibility for manifests outside of CompatibleVersions.
+type IncompatibleVersionError struct {
+	Tag        string
+	Compatible string
+}
+
+func (e *IncompatibleVersionError) Error() string {
+	return fmt.Sprintf("manifests %v are not supported by this CLI (%v), which supports '%v'. "+
+		"Set manifestSource.github.tag in cli_config.yaml to a supported version, e.g. '%v', or use a CLI that supports %v",
+		e.Tag, config.CLIVersion, e.Compatible, e.Compatible, e.Tag)
+}
@@ -0,0 +1,90 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onepanelio/cli/config"
+	"github.com/onepanelio/cli/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveVersionConstraint(t *testing.T) {
+	releases := []*github.Release{
+		{TagName: "v0.13.0"},
+		{TagName: "v0.12.2"},
+		{TagName: "v0.12.10"},
+		{TagName: "v0.12.0"},
+		{TagName: "nightly"},
+	}
+
+	tests := []struct {
+		constraint string
+		tag        string
+	}{
+		{constraint: "~0.12", tag: "v0.12.10"},
+		{constraint: ">=0.12 <0.13", tag: "v0.12.10"},
+		{constraint: ">=0.12, <0.12.5", tag: "v0.12.2"},
+		{constraint: ">=0.12", tag: "v0.13.0"},
+	}
+
+	for _, test := range tests {
+		assert.True(t, IsVersionConstraint(test.constraint))
+
+		release, err := ResolveVersionConstraint(test.constraint, releases)
+		assert.Nil(t, err, test.constraint)
+		assert.Equal(t, test.tag, release.TagName, test.constraint)
+	}
+
+	_, err := ResolveVersionConstraint("~0.14", releases)
+	assert.NotNil(t, err)
+
+	assert.False(t, IsVersionConstraint("v0.12.0"))
+	assert.False(t, IsVersionConstraint("latest"))
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	defer func(tag, compatibility string) {
+		config.ManifestsRepositoryTag = tag
+		config.ManifestsCompatibility = compatibility
+	}(config.ManifestsRepositoryTag, config.ManifestsCompatibility)
+
+	config.ManifestsRepositoryTag = "v0.12.1"
+	config.ManifestsCompatibility = ""
+	assert.Equal(t, "~0.12", CompatibleVersions())
+	assert.Nil(t, CheckCompatibility("v0.12.4"))
+	err := CheckCompatibility("v0.13.0")
+	assert.IsType(t, &IncompatibleVersionError{}, err)
+	assert.Contains(t, err.Error(), "manifestSource.github.tag")
+	assert.Nil(t, CheckCompatibility("4f5c1a9"))
+
+	config.ManifestsCompatibility = ">=0.12 <0.14"
+	assert.Nil(t, CheckCompatibility("v0.13.0"))
+	assert.NotNil(t, CheckCompatibility("v0.11.0"))
+
+	config.ManifestsRepositoryTag = ""
+	config.ManifestsCompatibility = ""
+	assert.Nil(t, CheckCompatibility("v0.11.0"))
+}
+
+func TestUpdateGithubSourceTag(t *testing.T) {
+	dir, err := ioutil.TempDir("", "opctl-source-config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "cli_config.yaml")
+	data := "manifestSource:\n  github:\n    tag: v0.12.0\n    repository: example/manifests\nverification:\n  enabled: true\n  publicKeys: [key]\n"
+	assert.Nil(t, ioutil.WriteFile(path, []byte(data), 0644))
+	assert.Nil(t, UpdateGithubSourceTag(path, "v0.13.0"))
+
+	sourceConfig, err := loadSourceConfig(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "v0.13.0", *sourceConfig.ManifestSourceConfig.Github.Tag)
+	assert.Equal(t, "example/manifests", sourceConfig.ManifestSourceConfig.Github.Repository)
+	assert.True(t, sourceConfig.Verification.Enabled)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("manifestSource:\n  directory:\n    folder: manifests\n"), 0644))
+	assert.NotNil(t, UpdateGithubSourceTag(path, "v0.13.0"))
+}
@@ -0,0 +1,476 @@
+package oci
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	// ManifestMediaType is the media type of the OCI image manifest that describes the artifact.
+	ManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	// ConfigMediaType is the media type of the (empty) config blob of a manifests artifact.
+	ConfigMediaType = "application/vnd.onepanel.manifests.config.v1+json"
+	// LayerMediaType is the media type of the .tar.gz layer holding the manifests directory.
+	LayerMediaType = "application/vnd.onepanel.manifests.layer.v1.tar+gzip"
+	// SignatureAnnotation is the manifest annotation holding the base64 encoded detached signature of the layer.
+	SignatureAnnotation = "io.onepanel.manifests.signature"
+)
+
+// Descriptor points to a blob in the registry.
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Manifest is an OCI image manifest.
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Reference identifies an artifact, e.g. oci://registry.example.com/onepanel/manifests:v0.10.0
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses registry/repository[:tag][@digest]. The oci:// prefix is optional.
+// The tag defaults to latest if neither a tag nor a digest is given.
+func ParseReference(reference string) (*Reference, error) {
+	trimmed := strings.TrimPrefix(reference, "oci://")
+
+	slash := strings.Index(trimmed, "/")
+	if slash <= 0 {
+		return nil, fmt.Errorf("reference '%v' must include the registry, e.g. oci://registry.example.com/onepanel/manifests:v0.10.0", reference)
+	}
+
+	ref := &Reference{
+		Registry:   trimmed[:slash],
+		Repository: trimmed[slash+1:],
+	}
+
+	if at := strings.Index(ref.Repository, "@"); at >= 0 {
+		ref.Digest = ref.Repository[at+1:]
+		ref.Repository = ref.Repository[:at]
+		if !strings.HasPrefix(ref.Digest, "sha256:") {
+			return nil, fmt.Errorf("reference '%v' has an unsupported digest, only sha256 is supported", reference)
+		}
+	}
+
+	if colon := strings.LastIndex(ref.Repository, ":"); colon >= 0 {
+		ref.Tag = ref.Repository[colon+1:]
+		ref.Repository = ref.Repository[:colon]
+	}
+
+	if ref.Repository == "" {
+		return nil, fmt.Errorf("reference '%v' is missing the repository", reference)
+	}
+
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+
+	return ref, nil
+}
+
+// manifestReference is the tag or digest used to get the manifest. The digest wins if both are set.
+func (r *Reference) manifestReference() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+
+	return r.Tag
+}
+
+func (r *Reference) String() string {
+	result := "oci://" + r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		result += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		result += "@" + r.Digest
+	}
+
+	return result
+}
+
+// Client talks to an OCI distribution registry.
+type Client struct {
+	HTTPClient *http.Client
+	Username   string
+	Password   string
+	// PlainHTTP uses http instead of https. localhost, 127.0.0.1 and ::1 registries always use http.
+	PlainHTTP bool
+
+	token string // bearer token from the registry's token service, if it uses one
+}
+
+// New creates a Client. Credentials are optional and only needed for private registries.
+func New(username, password string, plainHTTP bool) *Client {
+	return &Client{
+		HTTPClient: http.DefaultClient,
+		Username:   username,
+		Password:   password,
+		PlainHTTP:  plainHTTP,
+	}
+}
+
+// Digest returns the sha256 digest of data in the registry's format.
+func Digest(data []byte) string {
+	hash := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
+func (c *Client) baseUrl(ref *Reference) string {
+	scheme := "https"
+	if c.PlainHTTP || isLocalRegistry(ref.Registry) {
+		scheme = "http"
+	}
+
+	return scheme + "://" + ref.Registry + "/v2/" + ref.Repository
+}
+
+// isLocalRegistry returns true if the host of registry, without the port, is localhost, 127.0.0.1 or ::1.
+func isLocalRegistry(registry string) bool {
+	host := registry
+	if net.ParseIP(registry) == nil {
+		host = (&url.URL{Host: registry}).Hostname()
+	}
+
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+// do sends the request built by newRequest. If the registry asks for a bearer token,
+// one is fetched and the request is sent again.
+func (c *Client) do(newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		request, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		if c.token != "" {
+			request.Header.Set("Authorization", "Bearer "+c.token)
+		} else if c.Username != "" {
+			request.SetBasicAuth(c.Username, c.Password)
+		}
+
+		response, err := c.HTTPClient.Do(request)
+		if err != nil {
+			return nil, err
+		}
+
+		if response.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return response, nil
+		}
+
+		challenge := response.Header.Get("WWW-Authenticate")
+		response.Body.Close()
+		if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+			return nil, fmt.Errorf("%v %v: unauthorized, check the registry credentials", request.Method, request.URL)
+		}
+
+		if err := c.fetchToken(challenge); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("unreachable")
+}
+
+// fetchToken gets a bearer token from the realm in a WWW-Authenticate challenge.
+func (c *Client) fetchToken(challenge string) error {
+	params := make(map[string]string)
+	for _, part := range strings.Split(challenge[len("bearer "):], ",") {
+		keyValue := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(keyValue) == 2 {
+			params[keyValue[0]] = strings.Trim(keyValue[1], `"`)
+		}
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return fmt.Errorf("registry sent an invalid authentication challenge: %v", challenge)
+	}
+
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	request, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if c.Username != "" {
+		request.SetBasicAuth(c.Username, c.Password)
+	}
+
+	response, err := c.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to get a token from %v: %v", realm.Host, response.Status)
+	}
+
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return err
+	}
+
+	c.token = token.Token
+	if c.token == "" {
+		c.token = token.AccessToken
+	}
+
+	return nil
+}
+
+func checkStatus(response *http.Response, expected ...int) error {
+	for _, status := range expected {
+		if response.StatusCode == status {
+			return nil
+		}
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+
+	return fmt.Errorf("%v %v: unexpected status %v %v", response.Request.Method, response.Request.URL, response.Status, strings.TrimSpace(string(body)))
+}
+
+// GetManifest returns the manifest of ref and its digest.
+// If ref has a digest, the manifest must match it.
+func (c *Client) GetManifest(ref *Reference) (*Manifest, string, error) {
+	response, err := c.do(func() (*http.Request, error) {
+		request, err := http.NewRequest(http.MethodGet, c.baseUrl(ref)+"/manifests/"+ref.manifestReference(), nil)
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Accept", ManifestMediaType)
+		return request, nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+
+	if err := checkStatus(response, http.StatusOK); err != nil {
+		return nil, "", err
+	}
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	digest := Digest(data)
+	if ref.Digest != "" && ref.Digest != digest {
+		return nil, "", fmt.Errorf("manifest digest mismatch for %v: got %v", ref, digest)
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, "", err
+	}
+
+	return manifest, digest, nil
+}
+
+// ManifestsLayer returns the layer holding the manifests directory.
+func (m *Manifest) ManifestsLayer() (*Descriptor, error) {
+	for i := range m.Layers {
+		if m.Layers[i].MediaType == LayerMediaType {
+			return &m.Layers[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("artifact has no layer of type %v", LayerMediaType)
+}
+
+// DownloadBlob writes the blob described by descriptor to path, verifying its digest.
+func (c *Client) DownloadBlob(ref *Reference, descriptor *Descriptor, path string) error {
+	response, err := c.do(func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, c.baseUrl(ref)+"/blobs/"+descriptor.Digest, nil)
+	})
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if err := checkStatus(response, http.StatusOK); err != nil {
+		return err
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, hash), response.Body); err != nil {
+		return err
+	}
+
+	digest := "sha256:" + hex.EncodeToString(hash.Sum(nil))
+	if digest != descriptor.Digest {
+		return fmt.Errorf("blob digest mismatch for %v: expected %v, got %v", ref, descriptor.Digest, digest)
+	}
+
+	return nil
+}
+
+// blobExists returns true if the registry already has the blob, so it does not need to be uploaded again.
+func (c *Client) blobExists(ref *Reference, digest string) (bool, error) {
+	response, err := c.do(func() (*http.Request, error) {
+		return http.NewRequest(http.MethodHead, c.baseUrl(ref)+"/blobs/"+digest, nil)
+	})
+	if err != nil {
+		return false, err
+	}
+	response.Body.Close()
+
+	return response.StatusCode == http.StatusOK, nil
+}
+
+// UploadBlob uploads data with a monolithic upload and returns its descriptor.
+func (c *Client) UploadBlob(ref *Reference, mediaType string, data []byte) (*Descriptor, error) {
+	descriptor := &Descriptor{
+		MediaType: mediaType,
+		Digest:    Digest(data),
+		Size:      int64(len(data)),
+	}
+
+	exists, err := c.blobExists(ref, descriptor.Digest)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return descriptor, nil
+	}
+
+	response, err := c.do(func() (*http.Request, error) {
+		return http.NewRequest(http.MethodPost, c.baseUrl(ref)+"/blobs/uploads/", nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	response.Body.Close()
+
+	if err := checkStatus(response, http.StatusAccepted); err != nil {
+		return nil, err
+	}
+
+	location, err := response.Request.URL.Parse(response.Header.Get("Location"))
+	if err != nil {
+		return nil, err
+	}
+	query := location.Query()
+	query.Set("digest", descriptor.Digest)
+	location.RawQuery = query.Encode()
+
+	response, err = c.do(func() (*http.Request, error) {
+		request, err := http.NewRequest(http.MethodPut, location.String(), bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Content-Type", "application/octet-stream")
+		return request, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if err := checkStatus(response, http.StatusCreated); err != nil {
+		return nil, err
+	}
+
+	return descriptor, nil
+}
+
+// PutManifest uploads manifest under the tag of ref and returns its digest.
+func (c *Client) PutManifest(ref *Reference, manifest *Manifest) (string, error) {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := c.do(func() (*http.Request, error) {
+		request, err := http.NewRequest(http.MethodPut, c.baseUrl(ref)+"/manifests/"+ref.manifestReference(), bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Content-Type", ManifestMediaType)
+		return request, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if err := checkStatus(response, http.StatusCreated); err != nil {
+		return "", err
+	}
+
+	return Digest(data), nil
+}
+
+// PushManifests publishes the .tar.gz archive at archivePath as a manifests artifact and returns the manifest digest.
+// annotations are added to the manifest, e.g. to record the source version.
+func (c *Client) PushManifests(ref *Reference, archivePath string, annotations map[string]string) (string, error) {
+	if ref.Tag == "" {
+		return "", fmt.Errorf("reference %v needs a tag to push to", ref)
+	}
+
+	archive, err := ioutil.ReadFile(archivePath)
+	if err != nil {
+		return "", err
+	}
+
+	config, err := c.UploadBlob(ref, ConfigMediaType, []byte("{}"))
+	if err != nil {
+		return "", err
+	}
+
+	layer, err := c.UploadBlob(ref, LayerMediaType, archive)
+	if err != nil {
+		return "", err
+	}
+
+	manifest := &Manifest{
+		SchemaVersion: 2,
+		MediaType:     ManifestMediaType,
+		Config:        *config,
+		Layers:        []Descriptor{*layer},
+		Annotations:   annotations,
+	}
+
+	return c.PutManifest(&Reference{Registry: ref.Registry, Repository: ref.Repository, Tag: ref.Tag}, manifest)
+}
@@ -0,0 +1,153 @@
+package oci
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/onepanelio/cli/files"
+	"github.com/stretchr/testify/assert"
+)
+
+// testRegistry is an in memory stand-in for a registry, supporting only what Client uses.
+type testRegistry struct {
+	mutex     sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	uploads   int
+}
+
+func (r *testRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	switch {
+	case req.Method == http.MethodPost && strings.HasSuffix(path, "/blobs/uploads/"):
+		r.uploads++
+		w.Header().Set("Location", "/v2/"+path+"upload-id")
+		w.WriteHeader(http.StatusAccepted)
+	case req.Method == http.MethodPut && strings.Contains(path, "/blobs/uploads/"):
+		data, _ := ioutil.ReadAll(req.Body)
+		digest := req.URL.Query().Get("digest")
+		if Digest(data) != digest {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.blobs[digest] = data
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(path, "/blobs/"):
+		data, ok := r.blobs[path[strings.LastIndex(path, "/")+1:]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	case req.Method == http.MethodPut && strings.Contains(path, "/manifests/"):
+		data, _ := ioutil.ReadAll(req.Body)
+		r.manifests[path] = data
+		r.manifests[path[:strings.LastIndex(path, "/")+1]+Digest(data)] = data
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(path, "/manifests/"):
+		data, ok := r.manifests[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	ref, err := ParseReference("oci://registry.example.com:5000/onepanel/manifests:v0.10.0")
+	assert.Nil(t, err)
+	assert.Equal(t, "registry.example.com:5000", ref.Registry)
+	assert.Equal(t, "onepanel/manifests", ref.Repository)
+	assert.Equal(t, "v0.10.0", ref.Tag)
+
+	ref, err = ParseReference("registry.example.com/onepanel/manifests@sha256:abc")
+	assert.Nil(t, err)
+	assert.Equal(t, "", ref.Tag)
+	assert.Equal(t, "sha256:abc", ref.Digest)
+
+	ref, err = ParseReference("registry.example.com/onepanel/manifests")
+	assert.Nil(t, err)
+	assert.Equal(t, "latest", ref.Tag)
+
+	_, err = ParseReference("oci://manifests:v0.10.0")
+	assert.NotNil(t, err)
+}
+
+func TestIsLocalRegistry(t *testing.T) {
+	for _, registry := range []string{"localhost", "localhost:5000", "127.0.0.1:5000", "[::1]:5000", "::1"} {
+		assert.True(t, isLocalRegistry(registry), registry)
+	}
+
+	for _, registry := range []string{"localhost.example.com", "localhost.example.com:5000", "127.0.0.1.example.com", "registry.example.com"} {
+		assert.False(t, isLocalRegistry(registry), registry)
+	}
+}
+
+func TestClient_PushAndPull(t *testing.T) {
+	registry := &testRegistry{blobs: make(map[string][]byte), manifests: make(map[string][]byte)}
+	server := httptest.NewServer(registry)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "opctl-oci")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	manifestsPath := filepath.Join(dir, "manifests")
+	assert.Nil(t, os.MkdirAll(filepath.Join(manifestsPath, "istio", "base"), os.ModePerm))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(manifestsPath, "istio", "base", "vars.yaml"), []byte("istio: {}\n"), 0644))
+
+	archivePath := filepath.Join(dir, "manifests.tar.gz")
+	assert.Nil(t, files.Tar(manifestsPath, archivePath))
+
+	ref, err := ParseReference("oci://" + strings.TrimPrefix(server.URL, "http://") + "/onepanel/manifests:v0.1.0")
+	assert.Nil(t, err)
+
+	client := New("", "", true)
+	digest, err := client.PushManifests(ref, archivePath, nil)
+	assert.Nil(t, err)
+
+	// Pushing the same archive again does not upload the blobs again.
+	_, err = client.PushManifests(ref, archivePath, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, registry.uploads)
+
+	manifest, pulledDigest, err := client.GetManifest(ref)
+	assert.Nil(t, err)
+	assert.Equal(t, digest, pulledDigest)
+
+	layer, err := manifest.ManifestsLayer()
+	assert.Nil(t, err)
+
+	pulledPath := filepath.Join(dir, "pulled.tar.gz")
+	assert.Nil(t, client.DownloadBlob(ref, layer, pulledPath))
+
+	_, err = files.Untar(pulledPath, filepath.Join(dir, "pulled"))
+	assert.Nil(t, err)
+	assert.FileExists(t, filepath.Join(dir, "pulled", "istio", "base", "vars.yaml"))
+
+	// A reference by digest must match the manifest.
+	ref.Digest = digest
+	_, _, err = client.GetManifest(ref)
+	assert.Nil(t, err)
+
+	ref.Tag = "v0.1.0"
+	ref.Digest = Digest([]byte("something else"))
+	_, _, err = client.GetManifest(ref)
+	assert.NotNil(t, err)
+
+	// A tampered blob is rejected.
+	registry.blobs[layer.Digest] = []byte("tampered")
+	assert.NotNil(t, client.DownloadBlob(ref, layer, pulledPath))
+}
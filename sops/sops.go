@@ -0,0 +1,115 @@
+package sops
+
+import (
+	"fmt"
+	"time"
+
+	"go.mozilla.org/sops/v3"
+	"go.mozilla.org/sops/v3/aes"
+	"go.mozilla.org/sops/v3/age"
+	"go.mozilla.org/sops/v3/stores/yaml"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+const (
+	// MetadataKey is the key of the sops metadata in an encrypted document.
+	MetadataKey = "sops"
+	// AgeKeyEnv holds age identities, one AGE-SECRET-KEY-1 per line.
+	AgeKeyEnv = age.SopsAgeKeyEnv
+	// AgeKeyFileEnv is the path of a file with age identities. It defaults to sops/age/keys.txt in the user config directory.
+	AgeKeyFileEnv = age.SopsAgeKeyFileEnv
+)
+
+// File holds the data key and metadata of an encrypted document, to encrypt it again after it is changed.
+type File struct {
+	Metadata sops.Metadata
+	dataKey  []byte
+}
+
+// IsEncrypted returns true if document, a yaml document node, has sops metadata.
+func IsEncrypted(document *yamlv3.Node) bool {
+	if document.Kind != yamlv3.DocumentNode || len(document.Content) == 0 || document.Content[0].Kind != yamlv3.MappingNode {
+		return false
+	}
+
+	root := document.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == MetadataKey {
+			return root.Content[i+1].Kind == yamlv3.MappingNode
+		}
+	}
+
+	return false
+}
+
+// Decrypt decrypts data, a sops encrypted yaml file, and returns it in clear text, comments included.
+// The data key is decrypted like sops does, e.g. with the age identities of SOPS_AGE_KEY or SOPS_AGE_KEY_FILE, or with gpg.
+func Decrypt(data []byte) ([]byte, *File, error) {
+	store := &yaml.Store{}
+	tree, err := store.LoadEncryptedFile(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dataKey, err := tree.Metadata.GetDataKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cipher := aes.NewCipher()
+	mac, err := tree.Decrypt(dataKey, cipher)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	originalMac, err := cipher.Decrypt(tree.Metadata.MessageAuthenticationCode, dataKey, tree.Metadata.LastModified.Format(time.RFC3339))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to decrypt the mac: %v", err.Error())
+	}
+	if originalMac != mac {
+		return nil, nil, fmt.Errorf("mac does not match, the file was changed without sops")
+	}
+
+	plaintext, err := store.EmitPlainFile(tree.Branches)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return plaintext, &File{Metadata: tree.Metadata, dataKey: dataKey}, nil
+}
+
+// Encrypt encrypts plaintext, a yaml file, with the data key of f and returns it with the sops metadata.
+func (f *File) Encrypt(plaintext []byte) ([]byte, error) {
+	store := &yaml.Store{}
+	branches, err := store.LoadPlainFile(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, branch := range branches {
+		for _, item := range branch {
+			if item.Key == MetadataKey {
+				return nil, fmt.Errorf("document is already encrypted")
+			}
+		}
+	}
+
+	tree := sops.Tree{
+		Branches: branches,
+		Metadata: f.Metadata,
+	}
+
+	cipher := aes.NewCipher()
+	mac, err := tree.Encrypt(f.dataKey, cipher)
+	if err != nil {
+		return nil, err
+	}
+
+	tree.Metadata.LastModified = time.Now().UTC()
+	tree.Metadata.MessageAuthenticationCode, err = cipher.Encrypt(mac, f.dataKey, tree.Metadata.LastModified.Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+
+	return store.EmitEncryptedFile(tree)
+}
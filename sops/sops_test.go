@@ -0,0 +1,123 @@
+package sops
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+// The files in testdata were encrypted by sops 3.7.3 from params.yaml:
+//
+//	sops --encrypt --age age1tstdggw5jnefgch825z26fqm8et0884l7jamhphyqra33ahxqpnq0pamdp params.yaml > params.age.yaml
+//	sops --encrypt --pgp 3C021B054BB1A0479F597515655F4F3C99DB2AF6 params.yaml > params.pgp.yaml
+//
+// The keys are in age-keys.txt and pgp-key.asc, they are only used for these tests.
+const testAgeRecipient = "age1tstdggw5jnefgch825z26fqm8et0884l7jamhphyqra33ahxqpnq0pamdp"
+
+func readTestData(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	assert.Nil(t, err)
+
+	return data
+}
+
+func parseValues(t *testing.T, data []byte) map[string]interface{} {
+	values := make(map[string]interface{})
+	assert.Nil(t, yaml.Unmarshal(data, &values))
+
+	return values
+}
+
+func parseDocument(t *testing.T, data []byte) *yaml.Node {
+	document := &yaml.Node{}
+	assert.Nil(t, yaml.Unmarshal(data, document))
+
+	return document
+}
+
+// assertDecrypted checks that plaintext has the values of params.yaml, with their types, and its comments.
+func assertDecrypted(t *testing.T, plaintext []byte) {
+	assert.Equal(t, parseValues(t, readTestData(t, "params.yaml")), parseValues(t, plaintext))
+
+	values := parseValues(t, plaintext)
+	option := values["application"].(map[string]interface{})["nodePool"].(map[string]interface{})["options"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, 4, option["cpu"])
+	assert.Equal(t, 15.5, option["memory"])
+	assert.Equal(t, false, values["application"].(map[string]interface{})["insecure"])
+
+	assert.Contains(t, string(plaintext), "# Params of the test deployment")
+	assert.Contains(t, string(plaintext), "# rotated every 90 days")
+}
+
+// assertReencrypts encrypts plaintext again with file and checks it decrypts to the same values.
+func assertReencrypts(t *testing.T, file *File, plaintext []byte) {
+	encrypted, err := file.Encrypt(plaintext)
+	assert.Nil(t, err)
+	assert.True(t, IsEncrypted(parseDocument(t, encrypted)))
+	assert.NotContains(t, string(encrypted), "wJalrXUtnFEMI")
+	assert.NotContains(t, string(encrypted), "rotated every 90 days")
+	assert.Contains(t, string(encrypted), "endpoint_unencrypted: s3.amazonaws.com")
+
+	decrypted, _, err := Decrypt(encrypted)
+	assert.Nil(t, err)
+	assertDecrypted(t, decrypted)
+}
+
+func TestDecrypt_Age(t *testing.T) {
+	assert.Nil(t, os.Setenv(AgeKeyFileEnv, filepath.Join("testdata", "age-keys.txt")))
+	defer os.Unsetenv(AgeKeyFileEnv)
+
+	encrypted := readTestData(t, "params.age.yaml")
+	assert.True(t, IsEncrypted(parseDocument(t, encrypted)))
+	assert.False(t, IsEncrypted(parseDocument(t, readTestData(t, "params.yaml"))))
+
+	plaintext, file, err := Decrypt(encrypted)
+	assert.Nil(t, err)
+	assertDecrypted(t, plaintext)
+	assert.Equal(t, testAgeRecipient, file.Metadata.KeyGroups[0][0].ToString())
+
+	assertReencrypts(t, file, plaintext)
+
+	// The values are bound to their key, moving one makes decryption fail.
+	_, _, err = Decrypt([]byte(strings.Replace(string(encrypted), "fqdn:", "domain:", 1)))
+	assert.NotNil(t, err)
+
+	// Removing a value changes the mac.
+	_, _, err = Decrypt([]byte(strings.Replace(string(encrypted), "        endpoint_unencrypted: s3.amazonaws.com\n", "", 1)))
+	assert.NotNil(t, err)
+
+	assert.Nil(t, os.Setenv(AgeKeyFileEnv, filepath.Join("testdata", "missing.txt")))
+	_, _, err = Decrypt(encrypted)
+	assert.NotNil(t, err)
+}
+
+func TestDecrypt_PGP(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not installed")
+	}
+
+	home, err := ioutil.TempDir("", "opctl-gnupg")
+	assert.Nil(t, err)
+	defer os.RemoveAll(home)
+
+	assert.Nil(t, os.Setenv("GNUPGHOME", home))
+	defer os.Unsetenv("GNUPGHOME")
+	defer func() {
+		_ = exec.Command("gpgconf", "--kill", "gpg-agent").Run()
+	}()
+
+	output, err := exec.Command("gpg", "--batch", "--import", filepath.Join("testdata", "pgp-key.asc")).CombinedOutput()
+	assert.Nil(t, err, string(output))
+
+	plaintext, file, err := Decrypt(readTestData(t, "params.pgp.yaml"))
+	assert.Nil(t, err)
+	assertDecrypted(t, plaintext)
+
+	assertReencrypts(t, file, plaintext)
+}
@@ -211,7 +211,7 @@ func (b *Builder) Template() Kustomize {
 
 	sources := b.flattenSources()
 
-	//put them all in a slice and sort them and append them
+	// flattenSources orders the sources by component name, the stable sort keeps that order for the same Order.
 	sort.SliceStable(sources, func(i, j int) bool {
 		sourceI := sources[i]
 		sourceJ := sources[j]
@@ -226,10 +226,17 @@ func (b *Builder) Template() Kustomize {
 	return k
 }
 
+// flattenSources returns the sources of all the components, by component name.
 func (b *Builder) flattenSources() []Source {
 	sources := make([]Source, 0)
 
+	keys := make([]string, 0, len(b.Sources))
 	for key := range b.Sources {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
 		for i := range b.Sources[key] {
 			source := b.Sources[key][i]
 			sources = append(sources, source)
@@ -319,13 +326,14 @@ func (b *Builder) addVar(path string, value *files.ConfigVar) {
 	b.Vars[path] = path
 }
 
-// Returns an array containing the var names
+// Returns an array containing the var names, sorted
 func (b *Builder) VarsArray() []string {
 	result := make([]string, 0)
 
 	for key := range b.Vars {
 		result = append(result, key)
 	}
+	sort.Strings(result)
 
 	return result
 }
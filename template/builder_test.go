@@ -0,0 +1,56 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestBuilder_Template(t *testing.T) {
+	dir, err := ioutil.TempDir("", "opctl-manifests")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, path := range []string{
+		"common/application/base",
+		"common/istio/base",
+		"common/istio/overlays/gcp",
+		"common/monitoring/base",
+		"cert-manager/base",
+		"storage/base",
+		"storage/overlays/gcp",
+	} {
+		assert.Nil(t, os.MkdirAll(filepath.Join(dir, path), os.ModePerm))
+	}
+
+	render := func() string {
+		builder := NewBuilder(BuilderConfig{
+			ManifestRoot: dir,
+			Components:   []Component{{Name: "storage"}, {Name: "cert-manager"}},
+			Overlays:     []Overlay{{Name: "common/istio/overlays/gcp"}, {Name: "storage/overlays/gcp"}},
+		})
+		assert.Nil(t, builder.Build())
+
+		data, err := yaml.Marshal(builder.Template())
+		assert.Nil(t, err)
+
+		return string(data)
+	}
+
+	expected := render()
+	assert.Contains(t, expected, `resources:
+- common/application/base
+- common/istio/overlays/gcp
+- common/monitoring/base
+- cert-manager/base
+- storage/overlays/gcp
+`)
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, expected, render())
+	}
+}
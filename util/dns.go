@@ -0,0 +1,149 @@
+package util
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/rand"
+)
+
+// DNSVerifier checks that the DNS records of a deployment point to the ingress gateway
+// and that the web UI responds.
+type DNSVerifier struct {
+	Resolver   *net.Resolver
+	HTTPClient *http.Client
+	Timeout    time.Duration
+}
+
+// NewDNSVerifier creates a DNSVerifier. If dnsServer is set, e.g. 8.8.8.8:53, it is queried
+// instead of the system resolvers.
+func NewDNSVerifier(dnsServer string) *DNSVerifier {
+	resolver := net.DefaultResolver
+	if dnsServer != "" {
+		if _, _, err := net.SplitHostPort(dnsServer); err != nil {
+			dnsServer = net.JoinHostPort(dnsServer, "53")
+		}
+
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				dialer := net.Dialer{}
+				return dialer.DialContext(ctx, network, dnsServer)
+			},
+		}
+	}
+
+	dialer := &net.Dialer{
+		Resolver: resolver,
+	}
+
+	return &DNSVerifier{
+		Resolver: resolver,
+		HTTPClient: &http.Client{
+			Transport: &http.Transport{
+				DialContext: dialer.DialContext,
+				// The certificate might not be issued yet, we only care whether the UI responds.
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+			Timeout: 10 * time.Second,
+		},
+		Timeout: 10 * time.Second,
+	}
+}
+
+// Verify resolves fqdn and a random host of its wildcard domain and compares the answers
+// with target, the IP or hostname of the ingress gateway. It then requests webURL.
+func (v *DNSVerifier) Verify(fqdn, target, webURL string) *PreflightReport {
+	report := &PreflightReport{}
+
+	v.verifyHost(report, "DNS "+fqdn, fqdn, target)
+
+	wildcard := GetWildCardDNS(fqdn)
+	randomHost := strings.Replace(wildcard, "*", "opctl-"+rand.String(8), 1)
+	v.verifyHost(report, "DNS "+wildcard, randomHost, target)
+
+	v.verifyWebURL(report, webURL)
+
+	return report
+}
+
+func (v *DNSVerifier) lookupHost(host string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), v.Timeout)
+	defer cancel()
+
+	return v.Resolver.LookupHost(ctx, host)
+}
+
+func (v *DNSVerifier) verifyHost(report *PreflightReport, name, host, target string) {
+	addresses, err := v.lookupHost(host)
+	if err != nil {
+		report.add(name, PreflightFail, "unable to resolve %v: %v", host, err.Error())
+		return
+	}
+
+	expected := []string{target}
+	if !IsIpv4(target) {
+		expected, err = v.lookupHost(target)
+		if err != nil {
+			report.add(name, PreflightFail, "unable to resolve the ingress gateway %v: %v", target, err.Error())
+			return
+		}
+	}
+
+	for _, address := range addresses {
+		for _, expectedAddress := range expected {
+			if address == expectedAddress {
+				report.add(name, PreflightPass, "%v resolves to %v", host, address)
+				return
+			}
+		}
+	}
+
+	report.add(name, PreflightFail, "%v resolves to %v, expected %v", host, strings.Join(addresses, ", "), target)
+}
+
+func (v *DNSVerifier) verifyWebURL(report *PreflightReport, webURL string) {
+	name := "Web UI"
+
+	response, err := v.HTTPClient.Get(webURL)
+	if err != nil {
+		report.add(name, PreflightFail, "%v did not respond: %v", webURL, err.Error())
+		return
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusInternalServerError {
+		report.add(name, PreflightFail, "%v responded with %v", webURL, response.Status)
+		return
+	}
+
+	report.add(name, PreflightPass, "%v responded with %v", webURL, response.Status)
+}
+
+// IngressGatewayAddress returns the external IP, or hostname, of the istio ingress gateway.
+func IngressGatewayAddress() (string, error) {
+	for _, field := range []string{"ip", "hostname"} {
+		kubectlGetFlags := make(map[string]interface{})
+		kubectlGetFlags["output"] = fmt.Sprintf("jsonpath='{.status.loadBalancer.ingress[0].%v}'", field)
+		extraArgs := []string{}
+		stdout, stderr, err := KubectlGet("service", "istio-ingressgateway", "istio-system", extraArgs, kubectlGetFlags)
+		if err != nil {
+			return "", fmt.Errorf("unable to get %v from istio-ingressgateway service: %v", field, err.Error())
+		}
+		if stderr != "" {
+			return "", fmt.Errorf("unable to get %v from istio-ingressgateway service: %v", field, stderr)
+		}
+
+		address := strings.Trim(stdout, "'")
+		if address != "" {
+			return address, nil
+		}
+	}
+
+	return "", nil
+}
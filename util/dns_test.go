@@ -0,0 +1,106 @@
+package util
+
+import (
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startStubDNSServer answers A queries for names ending in one of the records' keys.
+// Every other query gets an empty answer.
+func startStubDNSServer(t *testing.T, records map[string]string) (address string, stop func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	go func() {
+		buffer := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+
+			response := stubDNSResponse(buffer[:n], records)
+			if response != nil {
+				conn.WriteTo(response, addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String(), func() { conn.Close() }
+}
+
+func stubDNSResponse(query []byte, records map[string]string) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+
+	// Read the question name
+	labels := make([]string, 0)
+	offset := 12
+	for offset < len(query) && query[offset] != 0 {
+		length := int(query[offset])
+		labels = append(labels, string(query[offset+1:offset+1+length]))
+		offset += length + 1
+	}
+	questionEnd := offset + 5
+	if questionEnd > len(query) {
+		return nil
+	}
+	name := strings.Join(labels, ".")
+	queryType := binary.BigEndian.Uint16(query[offset+1 : offset+3])
+
+	var ip net.IP
+	if queryType == 1 {
+		for suffix, address := range records {
+			if name == suffix || strings.HasSuffix(name, "."+suffix) {
+				ip = net.ParseIP(address).To4()
+			}
+		}
+	}
+
+	response := make([]byte, 0, 512)
+	response = append(response, query[0:2]...)            // id
+	response = append(response, 0x81, 0x80)               // standard response, recursion available
+	response = append(response, 0, 1)                     // questions
+	response = append(response, 0, byte(len(ip)/4), 0, 0) // answers, authority
+	response = append(response, 0, 0)                     // additional
+	response = append(response, query[12:questionEnd]...)
+	if ip != nil {
+		response = append(response, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+		response = append(response, ip...)
+	}
+
+	return response
+}
+
+func TestDNSVerifier_Verify(t *testing.T) {
+	webServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer webServer.Close()
+
+	dnsAddress, stop := startStubDNSServer(t, map[string]string{
+		"app.example.com": "10.0.0.1",
+		"example.com":     "10.0.0.1",
+		"other.com":       "10.0.0.2",
+	})
+	defer stop()
+
+	verifier := NewDNSVerifier(dnsAddress)
+
+	report := verifier.Verify("app.example.com", "10.0.0.1", webServer.URL)
+	assert.False(t, report.HasFailures(), report.String())
+	assert.Len(t, report.Results, 3)
+
+	report = verifier.Verify("app.other.com", "10.0.0.1", webServer.URL)
+	assert.True(t, report.HasFailures())
+	assert.Equal(t, PreflightFail, report.Results[0].Status)
+	assert.Contains(t, report.Results[0].Message, "expected 10.0.0.1")
+	assert.Equal(t, PreflightPass, report.Results[2].Status)
+}
@@ -3,6 +3,7 @@ package util
 import (
 	"fmt"
 	"github.com/iancoleman/strcase"
+	"github.com/onepanelio/cli/sops"
 	"gopkg.in/yaml.v3"
 	"io/ioutil"
 	"log"
@@ -62,9 +63,12 @@ type NodePair struct {
 }
 
 type DynamicYaml struct {
-	node *yaml.Node
+	node       *yaml.Node
+	encryption *sops.File
 }
 
+// LoadDynamicYamlFromFile reads the yaml at filePath. A sops encrypted file is decrypted, see sops.Decrypt,
+// and WriteToFile encrypts it again.
 func LoadDynamicYamlFromFile(filePath string) (*DynamicYaml, error) {
 	rawFileData, err := ioutil.ReadFile(filePath)
 	if err != nil {
@@ -83,6 +87,19 @@ func LoadDynamicYamlFromFile(filePath string) (*DynamicYaml, error) {
 		node: data,
 	}
 
+	if sops.IsEncrypted(data) {
+		var plaintext []byte
+		plaintext, dynamicYaml.encryption, err = sops.Decrypt(rawFileData)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt %v: %v", filePath, err.Error())
+		}
+
+		dynamicYaml.node = &yaml.Node{}
+		if err := yaml.Unmarshal(plaintext, dynamicYaml.node); err != nil {
+			return nil, err
+		}
+	}
+
 	return dynamicYaml, nil
 }
 
@@ -438,6 +455,35 @@ func (d *DynamicYaml) String() (string, error) {
 	return data, nil
 }
 
+// Encryption returns the keys d is encrypted with when it is written, nil if it is written in clear text.
+func (d *DynamicYaml) Encryption() *sops.File {
+	return d.encryption
+}
+
+// SetEncryption sets the keys d is encrypted with when it is written. Nil writes d in clear text.
+func (d *DynamicYaml) SetEncryption(encryption *sops.File) {
+	d.encryption = encryption
+}
+
+// WriteToFile writes the yaml to filePath, replacing the contents of the file. The yaml is encrypted if it has
+// an encryption, d itself stays decrypted.
+func (d *DynamicYaml) WriteToFile(filePath string) error {
+	data, err := d.String()
+	if err != nil {
+		return err
+	}
+
+	output := []byte(data)
+	if d.encryption != nil && d.node != nil {
+		output, err = d.encryption.Encrypt(output)
+		if err != nil {
+			return err
+		}
+	}
+
+	return ioutil.WriteFile(filePath, output, 0644)
+}
+
 func (d *DynamicYaml) DeleteByParts(parts ...string) error {
 	if len(d.node.Content) == 0 {
 		return nil
@@ -669,6 +715,66 @@ func mergeNodes(a, b *yaml.Node) {
 	}
 }
 
+// Override deep merges items into d in order, so the values of later items win. Unlike Merge, existing values are replaced.
+// Mappings are merged key by key. Any other value, including lists, null and secret references, replaces the value of the same key.
+// The nodes of items are copied, changing d afterwards does not change items.
+func (d *DynamicYaml) Override(items ...*DynamicYaml) {
+	for _, item := range items {
+		if item.node == nil || len(item.node.Content) == 0 {
+			continue
+		}
+
+		if d.node == nil {
+			d.node = &yaml.Node{
+				Kind: yaml.DocumentNode,
+			}
+		}
+
+		if len(d.node.Content) == 0 {
+			d.node.Content = append(d.node.Content, createMappingYamlNode())
+		}
+
+		d.node.Content[0] = overrideNode(d.node.Content[0], item.node.Content[0])
+	}
+}
+
+// overrideNode returns b merged over a. a is changed in place if both are mappings.
+func overrideNode(a, b *yaml.Node) *yaml.Node {
+	if a.Kind != yaml.MappingNode || b.Kind != yaml.MappingNode || isSecretRef(a) || isSecretRef(b) {
+		return copyNode(b)
+	}
+
+	for i := 0; i+1 < len(b.Content); i += 2 {
+		bKeyNode := b.Content[i]
+		bValueNode := b.Content[i+1]
+
+		alreadyExists := false
+		for j := 0; j+1 < len(a.Content); j += 2 {
+			if a.Content[j].Value == bKeyNode.Value {
+				a.Content[j+1] = overrideNode(a.Content[j+1], bValueNode)
+				alreadyExists = true
+				break
+			}
+		}
+
+		if !alreadyExists {
+			a.Content = append(a.Content, copyNode(bKeyNode), copyNode(bValueNode))
+		}
+	}
+
+	return a
+}
+
+func copyNode(node *yaml.Node) *yaml.Node {
+	nodeCopy := *node
+	nodeCopy.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		nodeCopy.Content[i] = copyNode(child)
+	}
+
+	return &nodeCopy
+}
+
 func flattenMap(path string, keyFormatter FlatMapKeyFormatter, node *yaml.Node, results map[string]NodePair) {
 	for i, childNode := range node.Content {
 		// this is a value node
@@ -0,0 +1,153 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+const (
+	hostsBlockBegin = "# BEGIN opctl managed block, do not edit"
+	hostsBlockEnd   = "# END opctl managed block"
+)
+
+// DefaultHostsFilePath returns the location of the hosts file of the current OS.
+func DefaultHostsFilePath() string {
+	if runtime.GOOS == "windows" {
+		return "C:\\Windows\\System32\\Drivers\\etc\\hosts"
+	}
+
+	return "/etc/hosts"
+}
+
+// HostsBlock returns the managed block pointing every hostname to ip, one hostname per line.
+// Hostnames are sorted and deduplicated so the block is the same for the same input.
+func HostsBlock(ip string, hostnames []string) string {
+	unique := make(map[string]bool)
+	for _, hostname := range hostnames {
+		unique[hostname] = true
+	}
+
+	sorted := make([]string, 0)
+	for hostname := range unique {
+		sorted = append(sorted, hostname)
+	}
+	sort.Strings(sorted)
+
+	builder := &strings.Builder{}
+	builder.WriteString(hostsBlockBegin + "\n")
+	for _, hostname := range sorted {
+		fmt.Fprintf(builder, "%v %v\n", ip, hostname)
+	}
+	builder.WriteString(hostsBlockEnd + "\n")
+
+	return builder.String()
+}
+
+// removeHostsBlock returns content without the managed block.
+func removeHostsBlock(content string) (string, error) {
+	begin := strings.Index(content, hostsBlockBegin)
+	if begin < 0 {
+		return content, nil
+	}
+
+	end := strings.Index(content[begin:], hostsBlockEnd)
+	if end < 0 {
+		return "", fmt.Errorf("the opctl block is missing its end marker: %v", hostsBlockEnd)
+	}
+	end += begin + len(hostsBlockEnd)
+	if end < len(content) && content[end] == '\n' {
+		end++
+	}
+
+	return content[:begin] + content[end:], nil
+}
+
+func writeHostsFile(path, original, updated string) (changed bool, err error) {
+	if original == updated {
+		return false, nil
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode()
+	}
+
+	if err := ioutil.WriteFile(path, []byte(updated), mode); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// SyncHostsFile replaces the managed block in the hosts file at path with one pointing hostnames to ip.
+// The rest of the file is left as is. Returns false if the file already had the same block.
+func SyncHostsFile(path, ip string, hostnames []string) (changed bool, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	original := string(data)
+
+	updated, err := removeHostsBlock(original)
+	if err != nil {
+		return false, err
+	}
+
+	if updated != "" && !strings.HasSuffix(updated, "\n") {
+		updated += "\n"
+	}
+	updated += HostsBlock(ip, hostnames)
+
+	return writeHostsFile(path, original, updated)
+}
+
+// CleanHostsFile removes the managed block from the hosts file at path.
+// Returns false if there was no block to remove.
+func CleanHostsFile(path string) (changed bool, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	original := string(data)
+
+	updated, err := removeHostsBlock(original)
+	if err != nil {
+		return false, err
+	}
+
+	return writeHostsFile(path, original, updated)
+}
+
+// VirtualServiceHosts returns the hosts of all istio VirtualServices that are served under domain,
+// e.g. the hostnames of workspaces.
+func VirtualServiceHosts(domain string) ([]string, error) {
+	flags := make(map[string]interface{})
+	flags["all-namespaces"] = true
+	flags["output"] = `jsonpath={range .items[*]}{range .spec.hosts[*]}{@}{"\n"}{end}{end}`
+	var extraArgs []string
+	stdout, stderr, err := KubectlGet("virtualservices", "", "", extraArgs, flags)
+	if err != nil {
+		return nil, err
+	}
+	if stderr != "" {
+		return nil, fmt.Errorf("%v", stderr)
+	}
+
+	hosts := make([]string, 0)
+	for _, host := range strings.Split(stdout, "\n") {
+		host = strings.TrimSpace(host)
+		if host == "" || strings.Contains(host, "*") {
+			continue
+		}
+
+		if strings.HasSuffix(host, "."+domain) {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts, nil
+}
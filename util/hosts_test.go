@@ -0,0 +1,63 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncHostsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "opctl-hosts")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	hostsPath := filepath.Join(dir, "hosts")
+	original := "127.0.0.1 localhost\n::1 localhost"
+	assert.Nil(t, ioutil.WriteFile(hostsPath, []byte(original), 0644))
+
+	changed, err := SyncHostsFile(hostsPath, "192.168.99.100", []string{"app.test", "ws--default.test", "app.test"})
+	assert.Nil(t, err)
+	assert.True(t, changed)
+
+	data, err := ioutil.ReadFile(hostsPath)
+	assert.Nil(t, err)
+	assert.Equal(t, `127.0.0.1 localhost
+::1 localhost
+# BEGIN opctl managed block, do not edit
+192.168.99.100 app.test
+192.168.99.100 ws--default.test
+# END opctl managed block
+`, string(data))
+
+	changed, err = SyncHostsFile(hostsPath, "192.168.99.100", []string{"ws--default.test", "app.test"})
+	assert.Nil(t, err)
+	assert.False(t, changed)
+
+	changed, err = SyncHostsFile(hostsPath, "192.168.99.101", []string{"app.test"})
+	assert.Nil(t, err)
+	assert.True(t, changed)
+
+	data, err = ioutil.ReadFile(hostsPath)
+	assert.Nil(t, err)
+	assert.Equal(t, `127.0.0.1 localhost
+::1 localhost
+# BEGIN opctl managed block, do not edit
+192.168.99.101 app.test
+# END opctl managed block
+`, string(data))
+
+	changed, err = CleanHostsFile(hostsPath)
+	assert.Nil(t, err)
+	assert.True(t, changed)
+
+	data, err = ioutil.ReadFile(hostsPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1 localhost\n::1 localhost\n", string(data))
+
+	changed, err = CleanHostsFile(hostsPath)
+	assert.Nil(t, err)
+	assert.False(t, changed)
+}
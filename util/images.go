@@ -0,0 +1,139 @@
+package util
+
+import (
+	"bytes"
+	"io"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// containerListKeys are the fields that hold lists of containers, in pod specs and in argo templates.
+var containerListKeys = map[string]bool{
+	"containers":          true,
+	"initContainers":      true,
+	"ephemeralContainers": true,
+	"sidecars":            true,
+}
+
+// containerKeys are the fields that hold a single container, like the container and script of argo templates.
+var containerKeys = map[string]bool{
+	"container": true,
+	"script":    true,
+}
+
+// ContainerImages returns the images of all containers in the multi document yaml, sorted and without duplicates.
+// Containers are found anywhere in a document, so deployments, cron jobs and custom resources like argo workflow templates are included.
+func ContainerImages(data []byte) ([]string, error) {
+	unique := make(map[string]bool)
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		document := &yaml.Node{}
+		if err := decoder.Decode(document); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		walkContainerImages(document, func(image string) {
+			unique[image] = true
+		})
+	}
+
+	images := make([]string, 0)
+	for image := range unique {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+
+	return images, nil
+}
+
+// walkContainerImages calls found with the image of every container under node.
+func walkContainerImages(node *yaml.Node, found func(image string)) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			value := node.Content[i+1]
+
+			if containerListKeys[key.Value] && value.Kind == yaml.SequenceNode {
+				for _, container := range value.Content {
+					if image := mappingValue(container, "image"); image != "" {
+						found(image)
+					}
+				}
+			}
+
+			if containerKeys[key.Value] {
+				if image := mappingValue(value, "image"); image != "" {
+					found(image)
+				}
+			}
+		}
+	}
+
+	for _, child := range node.Content {
+		walkContainerImages(child, found)
+	}
+}
+
+// mappingValue returns the scalar value of key in a mapping node, or an empty string.
+func mappingValue(node *yaml.Node, key string) string {
+	if node.Kind != yaml.MappingNode {
+		return ""
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key && node.Content[i+1].Kind == yaml.ScalarNode {
+			return node.Content[i+1].Value
+		}
+	}
+
+	return ""
+}
+
+// ObjectContainerImages returns the images of all containers in object, a kubernetes resource, sorted and without duplicates.
+func ObjectContainerImages(object map[string]interface{}) []string {
+	unique := make(map[string]bool)
+	walkContainers(object, func(container map[string]interface{}) {
+		if image, ok := container["image"].(string); ok && image != "" {
+			unique[image] = true
+		}
+	})
+
+	images := make([]string, 0)
+	for image := range unique {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+
+	return images
+}
+
+// walkContainers calls found with every container under value.
+func walkContainers(value interface{}, found func(container map[string]interface{})) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, child := range typed {
+			if list, ok := child.([]interface{}); ok && containerListKeys[key] {
+				for _, item := range list {
+					if container, ok := item.(map[string]interface{}); ok {
+						found(container)
+					}
+				}
+			}
+
+			if container, ok := child.(map[string]interface{}); ok && containerKeys[key] {
+				found(container)
+			}
+
+			walkContainers(child, found)
+		}
+	case []interface{}:
+		for _, child := range typed {
+			walkContainers(child, found)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerImages(t *testing.T) {
+	data := `apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: busybox:1.31
+      containers:
+      - name: core
+        image: onepanel/core:v0.10.0
+      - name: sidecar
+        image: busybox:1.31
+---
+apiVersion: batch/v1beta1
+kind: CronJob
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: backup
+            image: registry.example.com/backup@sha256:abc
+---
+apiVersion: argoproj.io/v1alpha1
+kind: WorkflowTemplate
+spec:
+  templates:
+  - name: train
+    container:
+      image: tensorflow/tensorflow:2.0.0
+    sidecars:
+    - name: tensorboard
+      image: tensorflow/tensorflow:2.0.0
+  - name: notify
+    script:
+      image: python:3.8
+---
+apiVersion: v1
+kind: ConfigMap
+data:
+  image: not-a-container
+`
+
+	images, err := ContainerImages([]byte(data))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"busybox:1.31", "onepanel/core:v0.10.0", "python:3.8", "registry.example.com/backup@sha256:abc", "tensorflow/tensorflow:2.0.0"}, images)
+}
+
+func TestObjectContainerImages(t *testing.T) {
+	object := map[string]interface{}{
+		"kind": "WorkflowTemplate",
+		"spec": map[string]interface{}{
+			"templates": []interface{}{
+				map[string]interface{}{
+					"container": map[string]interface{}{"image": "tensorflow/tensorflow:2.0.0"},
+					"sidecars": []interface{}{
+						map[string]interface{}{"image": "busybox:1.31"},
+					},
+				},
+				map[string]interface{}{
+					"script": map[string]interface{}{"image": "busybox:1.31"},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, []string{"busybox:1.31", "tensorflow/tensorflow:2.0.0"}, ObjectContainerImages(object))
+}
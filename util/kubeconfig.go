@@ -159,3 +159,14 @@ func RefreshAuthToken(in *restclient.Config) error {
 	_, _ = rt.RoundTrip(&req)
 	return nil
 }
+
+// NewKubernetesClient creates a clientset using the default kubeconfig loading rules.
+func NewKubernetesClient() (kubernetes.Interface, error) {
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return kubernetes.NewForConfig(config)
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	opConfig "github.com/onepanelio/cli/config"
 	"github.com/spf13/cobra"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/azure"
@@ -13,7 +12,6 @@ import (
 	"k8s.io/kubectl/pkg/cmd/get"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
 	"os"
-	"runtime"
 	"strconv"
 )
 
@@ -142,45 +140,11 @@ func validatePruneAll(prune, all bool, selector string) error {
 	return nil
 }
 
-func GetClusterIp(url string) {
-	kubectlGetFlags := make(map[string]interface{})
-	kubectlGetFlags["output"] = "jsonpath='{.status.loadBalancer.ingress[0].ip}'"
-	extraArgs := []string{}
-	stdout, stderr, err := KubectlGet("service", "istio-ingressgateway", "istio-system", extraArgs, kubectlGetFlags)
+// GetClusterIp prints the DNS record or hosts file line to add for the deployment at url, with the params in yamlFile.
+func GetClusterIp(url string, yamlFile *DynamicYaml) {
+	stdout, err := IngressGatewayAddress()
 	if err != nil {
-		fmt.Printf("[error] Unable to get IP from istio-ingressgateway service: %v", err.Error())
-		return
-	}
-	if stderr != "" {
-		fmt.Printf("[error] Unable to get IP from istio-ingressgateway service: %v", stderr)
-		return
-	}
-
-	if stdout == "" || stdout == "''" {
-		kubectlGetFlags["output"] = "jsonpath='{.status.loadBalancer.ingress[0].hostname}'"
-		extraArgs := []string{}
-		stdout, stderr, err = KubectlGet("service", "istio-ingressgateway", "istio-system", extraArgs, kubectlGetFlags)
-		if err != nil {
-			fmt.Printf("[error] Unable to get Hostname from istio-ingressgateway service: %v", err.Error())
-			return
-		}
-		if stderr != "" {
-			fmt.Printf("[error] Unable to get Hostname from istio-ingressgateway service: %v", stderr)
-			return
-		}
-	}
-
-	configFilePath := "config.yaml"
-
-	config, err := opConfig.FromFile(configFilePath)
-	if err != nil {
-		fmt.Printf("Unable to read configuration file: %v", err.Error())
-		return
-	}
-
-	yamlFile, err := LoadDynamicYamlFromFile(config.Spec.Params)
-	if err != nil {
-		fmt.Printf("Unable to load yaml file: %v", err.Error())
+		fmt.Printf("[error] %v", err.Error())
 		return
 	}
 
@@ -190,12 +154,9 @@ func GetClusterIp(url string) {
 		if provider == "minikube" || provider == "microk8s" {
 			fqdn := yamlFile.GetValue("application.fqdn").Value
 
-			hostsPath := "/etc/hosts"
-			if runtime.GOOS == "windows" {
-				hostsPath = "C:\\Windows\\System32\\Drivers\\etc\\hosts"
-			}
-
-			fmt.Printf("\nIn your %v file, add %v and point it to %v\n", hostsPath, stdout, fqdn)
+			dnsRecordMessage = "hosts"
+			fmt.Printf("\nIn your %v file, add the line \"%v %v\"\n", DefaultHostsFilePath(), stdout, fqdn)
+			fmt.Printf("Or run `sudo opctl hosts sync` to add it, along with your workspaces\n")
 		} else {
 			dnsRecordMessage = "an A"
 			if !IsIpv4(stdout) {
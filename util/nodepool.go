@@ -0,0 +1,158 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// InstanceTypeLabel is the well known label holding a node's instance type.
+	InstanceTypeLabel = "node.kubernetes.io/instance-type"
+	// InstanceTypeLabelBeta is the label used for the instance type before Kubernetes 1.17.
+	InstanceTypeLabelBeta = "beta.kubernetes.io/instance-type"
+	// nodePoolLimitPercentage is the share of a node's allocatable resources offered as limits.
+	// The remainder is left for system pods and daemon sets.
+	nodePoolLimitPercentage = 85
+)
+
+// gpuModelLabels are node labels that name the GPU model, checked in order.
+var gpuModelLabels = []string{
+	"cloud.google.com/gke-accelerator",
+	"nvidia.com/gpu.product",
+	"accelerator",
+}
+
+// NodePoolOptionResources are the resource limits of a node pool option.
+type NodePoolOptionResources struct {
+	Limits map[string]string `yaml:"limits"`
+}
+
+// NodePoolOption is an entry of application.nodePool.options in params.yaml.
+type NodePoolOption struct {
+	Name      string                   `yaml:"name"`
+	Value     string                   `yaml:"value"`
+	Resources *NodePoolOptionResources `yaml:"resources,omitempty"`
+}
+
+// NodeInstanceType returns the value of label on the node. If label is empty,
+// the well known instance type labels are checked.
+func NodeInstanceType(node *corev1.Node, label string) string {
+	if label != "" {
+		return node.Labels[label]
+	}
+
+	if instanceType, ok := node.Labels[InstanceTypeLabel]; ok {
+		return instanceType
+	}
+
+	return node.Labels[InstanceTypeLabelBeta]
+}
+
+// NodePoolOptionsFromNodes groups nodes by their instance type and creates one node pool option per group.
+// The limits of an option are based on the smallest node of the group, so they fit on every node.
+// Nodes without an instance type are skipped.
+func NodePoolOptionsFromNodes(nodes []corev1.Node, label string) []NodePoolOption {
+	groups := make(map[string][]*corev1.Node)
+	for i := range nodes {
+		node := &nodes[i]
+		instanceType := NodeInstanceType(node, label)
+		if instanceType == "" {
+			continue
+		}
+
+		groups[instanceType] = append(groups[instanceType], node)
+	}
+
+	instanceTypes := make([]string, 0)
+	for instanceType := range groups {
+		instanceTypes = append(instanceTypes, instanceType)
+	}
+	sort.Strings(instanceTypes)
+
+	options := make([]NodePoolOption, 0)
+	for _, instanceType := range instanceTypes {
+		options = append(options, nodePoolOptionFromGroup(instanceType, groups[instanceType]))
+	}
+
+	return options
+}
+
+func nodePoolOptionFromGroup(instanceType string, nodes []*corev1.Node) NodePoolOption {
+	cpuCapacity := minQuantity(nodes, corev1.ResourceCPU, false)
+	memoryCapacity := minQuantity(nodes, corev1.ResourceMemory, false)
+	cpuAllocatable := minQuantity(nodes, corev1.ResourceCPU, true)
+	memoryAllocatable := minQuantity(nodes, corev1.ResourceMemory, true)
+
+	memoryGB := (memoryCapacity.Value() + (1 << 29)) >> 30
+	name := fmt.Sprintf("CPU: %v, RAM: %vGB", cpuCapacity.Value(), memoryGB)
+
+	cpuLimit := roundDown(cpuAllocatable.MilliValue()*nodePoolLimitPercentage/100, 100)
+	memoryLimit := roundDown((memoryAllocatable.Value()*nodePoolLimitPercentage/100)>>20, 100)
+
+	limits := map[string]string{
+		"cpu":    fmt.Sprintf("%vm", cpuLimit),
+		"memory": fmt.Sprintf("%vMi", memoryLimit),
+	}
+
+	for _, resourceName := range GPUResourceNames {
+		gpus := minQuantity(nodes, resourceName, true)
+		if gpus.IsZero() {
+			continue
+		}
+
+		limits[string(resourceName)] = gpus.String()
+		name = fmt.Sprintf("GPU: %vx%v, %v", gpus.String(), gpuModel(nodes[0], resourceName), name)
+	}
+
+	return NodePoolOption{
+		Name:  name,
+		Value: instanceType,
+		Resources: &NodePoolOptionResources{
+			Limits: limits,
+		},
+	}
+}
+
+func gpuModel(node *corev1.Node, resourceName corev1.ResourceName) string {
+	for _, label := range gpuModelLabels {
+		if model, ok := node.Labels[label]; ok && model != "" {
+			return model
+		}
+	}
+
+	return strings.ToUpper(strings.Split(string(resourceName), ".")[0])
+}
+
+func roundDown(value, step int64) int64 {
+	return value / step * step
+}
+
+func minQuantity(nodes []*corev1.Node, resourceName corev1.ResourceName, allocatable bool) resource.Quantity {
+	var result *resource.Quantity
+	for _, node := range nodes {
+		resources := node.Status.Capacity
+		if allocatable {
+			resources = node.Status.Allocatable
+		}
+
+		quantity, ok := resources[resourceName]
+		if !ok {
+			return resource.Quantity{}
+		}
+
+		if result == nil || quantity.Cmp(*result) < 0 {
+			copied := quantity.DeepCopy()
+			result = &copied
+		}
+	}
+
+	if result == nil {
+		return resource.Quantity{}
+	}
+
+	return *result
+}
@@ -0,0 +1,59 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testNode(name, instanceType, cpu, memory, gpus string) corev1.Node {
+	resources := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse(cpu),
+		corev1.ResourceMemory: resource.MustParse(memory),
+	}
+	if gpus != "" {
+		resources["nvidia.com/gpu"] = resource.MustParse(gpus)
+	}
+
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{InstanceTypeLabel: instanceType},
+		},
+		Status: corev1.NodeStatus{
+			Capacity:    resources,
+			Allocatable: resources,
+		},
+	}
+}
+
+func TestNodePoolOptionsFromNodes(t *testing.T) {
+	nodes := []corev1.Node{
+		testNode("b", "Standard_NC6", "6", "56Gi", "1"),
+		testNode("a-1", "Standard_D2s_v3", "2", "8Gi", ""),
+		testNode("a-2", "Standard_D2s_v3", "2", "7Gi", ""),
+		{ObjectMeta: metav1.ObjectMeta{Name: "unlabeled"}},
+	}
+
+	options := NodePoolOptionsFromNodes(nodes, "")
+
+	assert.Equal(t, []NodePoolOption{
+		{
+			Name:  "CPU: 2, RAM: 7GB",
+			Value: "Standard_D2s_v3",
+			Resources: &NodePoolOptionResources{
+				Limits: map[string]string{"cpu": "1700m", "memory": "6000Mi"},
+			},
+		},
+		{
+			Name:  "GPU: 1xNVIDIA, CPU: 6, RAM: 56GB",
+			Value: "Standard_NC6",
+			Resources: &NodePoolOptionResources{
+				Limits: map[string]string{"cpu": "5100m", "memory": "48700Mi", "nvidia.com/gpu": "1"},
+			},
+		},
+	}, options)
+}
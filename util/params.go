@@ -0,0 +1,416 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	opConfig "github.com/onepanelio/cli/config"
+	"gopkg.in/yaml.v3"
+)
+
+// ParamEnvironmentPrefix starts the environment variables that override a param. The parts of the key are separated
+// by __, e.g. OPCTL_PARAM_APPLICATION__NODE_POOL__LABEL sets application.nodePool.label.
+const ParamEnvironmentPrefix = "OPCTL_PARAM_"
+
+// ParamDifference is a param that is different between two params files.
+type ParamDifference struct {
+	Key     string
+	From    string
+	To      string
+	Added   bool // the param is only set in to
+	Removed bool // the param is only set in from
+}
+
+// LoadParams loads and merges the params files of config, then sets the param overrides of config.
+// Later files win, see DynamicYaml.Override, and overrides win over files.
+func LoadParams(config *opConfig.Config) (*DynamicYaml, error) {
+	params, _, err := LoadParamsWithOrigins(config)
+
+	return params, err
+}
+
+// LoadParamsWithOrigins is LoadParams, along with the file or override each value came from, see LoadParamsFiles.
+func LoadParamsWithOrigins(config *opConfig.Config) (params *DynamicYaml, origins map[string]string, err error) {
+	params, origins, err = LoadParamsFiles(config.Spec.Params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := ApplyParamOverrides(params, config.ParamOverrides, origins); err != nil {
+		return nil, nil, err
+	}
+
+	return params, origins, nil
+}
+
+// LoadParamsFiles loads and merges the params files in paths, later files win. The origins map the dotted key of each
+// value of the merged params, as in DiffParams, to the file it came from.
+func LoadParamsFiles(paths []string) (params *DynamicYaml, origins map[string]string, err error) {
+	if len(paths) == 0 {
+		return nil, nil, fmt.Errorf("no parameters file is set")
+	}
+
+	params = &DynamicYaml{}
+	fileOrigins := make(map[string]string)
+	for _, path := range paths {
+		file, err := LoadDynamicYamlFromFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		params.Override(file)
+		for key := range flattenValues(file) {
+			fileOrigins[key] = path
+		}
+	}
+
+	// A later file can replace a mapping of an earlier one, so only the keys left after merging have an origin.
+	origins = make(map[string]string)
+	for key := range flattenValues(params) {
+		origins[key] = fileOrigins[key]
+	}
+
+	return params, origins, nil
+}
+
+// ParamOverrides returns the overrides set by the environment variables in environ, formatted like os.Environ,
+// the --set-file assignments and the --set assignments, in the order they are applied: environment variables,
+// then --set-file, then --set. Later overrides win.
+func ParamOverrides(environ, setFiles, sets []string) ([]opConfig.ParamOverride, error) {
+	overrides := make([]opConfig.ParamOverride, 0)
+
+	environmentOverrides := make([]opConfig.ParamOverride, 0)
+	for _, variable := range environ {
+		if !strings.HasPrefix(variable, ParamEnvironmentPrefix) {
+			continue
+		}
+
+		parts := strings.SplitN(variable, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key, err := environmentParamKey(strings.TrimPrefix(parts[0], ParamEnvironmentPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", parts[0], err.Error())
+		}
+
+		environmentOverrides = append(environmentOverrides, opConfig.ParamOverride{
+			Key:        key,
+			Value:      parts[1],
+			Source:     parts[0],
+			IgnoreCase: true,
+		})
+	}
+	sort.Slice(environmentOverrides, func(i, j int) bool {
+		return environmentOverrides[i].Source < environmentOverrides[j].Source
+	})
+	overrides = append(overrides, environmentOverrides...)
+
+	for _, assignment := range setFiles {
+		key, path, err := splitParamAssignment(assignment)
+		if err != nil {
+			return nil, fmt.Errorf("--set-file %v: %v", assignment, err.Error())
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("--set-file %v: %v", assignment, err.Error())
+		}
+
+		overrides = append(overrides, opConfig.ParamOverride{
+			Key:    key,
+			Value:  string(content),
+			Source: "--set-file " + path,
+			String: true,
+		})
+	}
+
+	for _, assignment := range sets {
+		key, value, err := splitParamAssignment(assignment)
+		if err != nil {
+			return nil, fmt.Errorf("--set %v: %v", assignment, err.Error())
+		}
+
+		overrides = append(overrides, opConfig.ParamOverride{
+			Key:    key,
+			Value:  value,
+			Source: "--set",
+		})
+	}
+
+	return overrides, nil
+}
+
+// splitParamAssignment splits key=value.
+func splitParamAssignment(assignment string) (key, value string, err error) {
+	parts := strings.SplitN(assignment, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", fmt.Errorf("expected key=value, e.g. application.fqdn=example.com")
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// environmentParamKey turns the name of an environment variable, without ParamEnvironmentPrefix, into a dotted key,
+// e.g. APPLICATION__NODE_POOL__LABEL into application.nodePool.label.
+func environmentParamKey(name string) (string, error) {
+	parts := strings.Split(name, "__")
+	for i, part := range parts {
+		if part == "" {
+			return "", fmt.Errorf("expected %v<KEY>__<KEY>, e.g. %vAPPLICATION__FQDN", ParamEnvironmentPrefix, ParamEnvironmentPrefix)
+		}
+
+		parts[i] = LowerCamelCaseStringFormat(strings.ToLower(part), "_")
+	}
+
+	return strings.Join(parts, "."), nil
+}
+
+// ApplyParamOverrides sets each override in params, in order, and records its source in origins.
+// Only values that are not mappings or lists can be overridden, a secret reference can.
+func ApplyParamOverrides(params *DynamicYaml, overrides []opConfig.ParamOverride, origins map[string]string) error {
+	for _, override := range overrides {
+		parts, err := resolveParamOverrideKey(params, override)
+		if err != nil {
+			return err
+		}
+
+		if _, err := params.PutByPartsNode(parts, paramOverrideNode(override)); err != nil {
+			return fmt.Errorf("%v: %v", override.Source, err.Error())
+		}
+
+		origins[strings.Join(parts, ".")] = override.Source
+	}
+
+	return nil
+}
+
+// resolveParamOverrideKey returns the parts of the key of override, using the existing keys of params when
+// the override ignores case.
+func resolveParamOverrideKey(params *DynamicYaml, override opConfig.ParamOverride) ([]string, error) {
+	parts := strings.Split(override.Key, ".")
+
+	var node *yaml.Node
+	if params.node != nil && len(params.node.Content) != 0 {
+		node = params.node.Content[0]
+	}
+
+	for i, part := range parts {
+		if node == nil {
+			break
+		}
+		if node.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%v: unable to set %v, %v is not a mapping", override.Source, override.Key, strings.Join(parts[:i], "."))
+		}
+
+		var value *yaml.Node
+		parts[i], value = findMappingKey(node, part, override.IgnoreCase)
+		node = value
+	}
+
+	if node != nil && !isSecretRef(node) && (node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode) {
+		return nil, fmt.Errorf("%v: unable to set %v, only values that are not mappings or lists can be overridden", override.Source, override.Key)
+	}
+
+	return parts, nil
+}
+
+// findMappingKey returns the key of mapping matching key, and its value. An exact match is preferred over one that
+// ignores case. If there is none, key is returned with a nil value.
+func findMappingKey(mapping *yaml.Node, key string, ignoreCase bool) (string, *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return key, mapping.Content[i+1]
+		}
+	}
+
+	if ignoreCase {
+		for i := 0; i+1 < len(mapping.Content); i += 2 {
+			if strings.EqualFold(mapping.Content[i].Value, key) {
+				return mapping.Content[i].Value, mapping.Content[i+1]
+			}
+		}
+	}
+
+	return key, nil
+}
+
+// paramOverrideNode returns the value of override. Unless the override is a string, its type is the one
+// the value would have if it was written unquoted in a params file, e.g. true is a bool and 8080 an int.
+func paramOverrideNode(override opConfig.ParamOverride) *yaml.Node {
+	node := &yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Tag:   "!!str",
+		Value: override.Value,
+	}
+	if override.String {
+		return node
+	}
+
+	decoded := &yaml.Node{}
+	if err := yaml.Unmarshal([]byte(override.Value), decoded); err != nil || len(decoded.Content) != 1 {
+		return node
+	}
+	if value := decoded.Content[0]; value.Kind == yaml.ScalarNode && value.Value == override.Value {
+		node.Tag = value.Tag
+	}
+
+	return node
+}
+
+// CommentOrigins sets the line comment of each value of params to the file or override it came from.
+func CommentOrigins(params *DynamicYaml, origins map[string]string) {
+	if params.node == nil || len(params.node.Content) == 0 {
+		return
+	}
+
+	commentOriginNode("", params.node.Content[0], origins)
+}
+
+func commentOriginNode(path string, node *yaml.Node, origins map[string]string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := AppendDotFlatMapKeyFormatter(path, node.Content[i].Value)
+		value := node.Content[i+1]
+
+		if value.Kind == yaml.MappingNode {
+			commentOriginNode(key, value, origins)
+			continue
+		}
+
+		if origin, ok := origins[key]; ok {
+			// Lists are commented on the key, so the comment is not mixed up with the items.
+			if value.Kind == yaml.SequenceNode && value.Style != yaml.FlowStyle {
+				node.Content[i].LineComment = origin
+			} else {
+				value.LineComment = origin
+			}
+		}
+	}
+}
+
+// MissingParams returns the values of defaults that are not set in params, e.g. the vars of the components that are added,
+// so they can be written to a params file without replacing the values set by the other params files.
+// The first of defaults wins for the keys several of them set, see DynamicYaml.Merge.
+func MissingParams(params *DynamicYaml, defaults ...*DynamicYaml) *DynamicYaml {
+	missing := &DynamicYaml{}
+	for _, item := range defaults {
+		if item.node == nil {
+			continue
+		}
+		missing.Merge(&DynamicYaml{node: copyNode(item.node)})
+	}
+
+	if missing.node == nil || len(missing.node.Content) == 0 || params.node == nil || len(params.node.Content) == 0 {
+		return missing
+	}
+	removeSetValues(missing.node.Content[0], params.node.Content[0])
+
+	return missing
+}
+
+// removeSetValues removes the keys of missing that are set in params. Mappings are compared key by key,
+// any other value, including a secret reference, is set as a whole.
+func removeSetValues(missing, params *yaml.Node) {
+	if missing.Kind != yaml.MappingNode || params.Kind != yaml.MappingNode {
+		return
+	}
+
+	content := make([]*yaml.Node, 0, len(missing.Content))
+	for i := 0; i+1 < len(missing.Content); i += 2 {
+		key, value := missing.Content[i], missing.Content[i+1]
+
+		var setValue *yaml.Node
+		for j := 0; j+1 < len(params.Content); j += 2 {
+			if params.Content[j].Value == key.Value {
+				setValue = params.Content[j+1]
+				break
+			}
+		}
+
+		if setValue != nil {
+			if value.Kind != yaml.MappingNode || setValue.Kind != yaml.MappingNode || isSecretRef(setValue) {
+				continue
+			}
+
+			removeSetValues(value, setValue)
+			if len(value.Content) == 0 {
+				continue
+			}
+		}
+
+		content = append(content, key, value)
+	}
+	missing.Content = content
+}
+
+// DiffParams returns the params that are added, removed or changed from from to to, sorted by key.
+func DiffParams(from, to *DynamicYaml) []ParamDifference {
+	fromValues := flattenValues(from)
+	toValues := flattenValues(to)
+
+	differences := make([]ParamDifference, 0)
+	for key, fromValue := range fromValues {
+		toValue, ok := toValues[key]
+		if !ok {
+			differences = append(differences, ParamDifference{Key: key, From: fromValue, Removed: true})
+		} else if toValue != fromValue {
+			differences = append(differences, ParamDifference{Key: key, From: fromValue, To: toValue})
+		}
+	}
+
+	for key, toValue := range toValues {
+		if _, ok := fromValues[key]; !ok {
+			differences = append(differences, ParamDifference{Key: key, To: toValue, Added: true})
+		}
+	}
+
+	sort.Slice(differences, func(i, j int) bool {
+		return differences[i].Key < differences[j].Key
+	})
+
+	return differences
+}
+
+// flattenValues returns the values of d keyed by their dotted path. Unlike Flatten, lists are included,
+// with the list as yaml for a value.
+func flattenValues(d *DynamicYaml) map[string]string {
+	values := make(map[string]string)
+	if d.node == nil || len(d.node.Content) == 0 {
+		return values
+	}
+
+	flattenValueNode("", d.node.Content[0], values)
+
+	return values
+}
+
+func flattenValueNode(path string, node *yaml.Node, values map[string]string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := AppendDotFlatMapKeyFormatter(path, node.Content[i].Value)
+		value := node.Content[i+1]
+
+		switch value.Kind {
+		case yaml.MappingNode:
+			flattenValueNode(key, value, values)
+		case yaml.ScalarNode:
+			values[key] = value.Value
+		default:
+			data, err := yaml.Marshal(value)
+			if err != nil {
+				continue
+			}
+			values[key] = strings.TrimSpace(string(data))
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	opConfig "github.com/onepanelio/cli/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadParams(t *testing.T) {
+	dir, err := ioutil.TempDir("", "opctl-params")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	basePath := filepath.Join(dir, "params.yaml")
+	assert.Nil(t, ioutil.WriteFile(basePath, []byte("application:\n  fqdn: staging.example.com\n  provider: gke\nlogging:\n  image: fluentd\n"), 0644))
+
+	environmentPath := filepath.Join(dir, "prod.yaml")
+	assert.Nil(t, ioutil.WriteFile(environmentPath, []byte("# Params of the prod environment\n"), 0644))
+
+	config := &opConfig.Config{
+		Spec: opConfig.ConfigSpec{
+			Params: opConfig.ParamsFiles{basePath, environmentPath},
+		},
+	}
+
+	params, err := LoadParams(config)
+	assert.Nil(t, err)
+	assert.Equal(t, "staging.example.com", params.GetValue("application.fqdn").Value)
+
+	assert.Nil(t, ioutil.WriteFile(environmentPath, []byte("application:\n  fqdn: prod.example.com\n"), 0644))
+	params, err = LoadParams(config)
+	assert.Nil(t, err)
+	assert.Equal(t, "prod.example.com", params.GetValue("application.fqdn").Value)
+	assert.Equal(t, "gke", params.GetValue("application.provider").Value)
+
+	params, origins, err := LoadParamsFiles(config.Spec.Params)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"application.fqdn":     environmentPath,
+		"application.provider": basePath,
+		"logging.image":        basePath,
+	}, origins)
+
+	base, err := LoadDynamicYamlFromFile(basePath)
+	assert.Nil(t, err)
+	params.Put("application.nodePool.options", []string{"Standard_D4s_v3"})
+	assert.Nil(t, params.Delete("logging"))
+
+	assert.Equal(t, []ParamDifference{
+		{Key: "application.fqdn", From: "staging.example.com", To: "prod.example.com"},
+		{Key: "application.nodePool.options", To: "[Standard_D4s_v3]", Added: true},
+		{Key: "logging.image", From: "fluentd", Removed: true},
+	}, DiffParams(base, params))
+}
+
+func TestDynamicYaml_Override(t *testing.T) {
+	base, err := LoadDynamicYamlFromString("application:\n  fqdn: example.com\n  defaultNamespace: example\n  nodePool:\n    options:\n    - name: CPU\n    - name: GPU\n")
+	assert.Nil(t, err)
+	secrets, err := LoadDynamicYamlFromString("application:\n  fqdn: prod.example.com\n  nodePool:\n    options:\n    - name: GPU\n")
+	assert.Nil(t, err)
+
+	base.Override(secrets)
+
+	assert.Equal(t, "prod.example.com", base.GetValue("application.fqdn").Value)
+	assert.Equal(t, "example", base.GetValue("application.defaultNamespace").Value)
+	assert.Len(t, base.GetValue("application.nodePool.options").Content, 1)
+}
+
+func TestParamOverrides(t *testing.T) {
+	dir, err := ioutil.TempDir("", "opctl-params")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	passwordPath := filepath.Join(dir, "password")
+	assert.Nil(t, ioutil.WriteFile(passwordPath, []byte("true"), 0644))
+
+	environ := []string{
+		"HOME=/root",
+		"OPCTL_PARAM_APPLICATION__FQDN=env.example.com",
+		"OPCTL_PARAM_APPLICATION__NODE_POOL__LABEL=node.kubernetes.io/instance-type",
+		"OPCTL_PARAM_DATABASE__PORT=5433",
+	}
+	overrides, err := ParamOverrides(environ, []string{"database.password=" + passwordPath}, []string{"application.fqdn=set.example.com", "application.insecure=true"})
+	assert.Nil(t, err)
+
+	params, err := LoadDynamicYamlFromString("application:\n  fqdn: example.com\n  nodePool:\n    label: beta.kubernetes.io/instance-type\ndatabase:\n  port: 5432\n")
+	assert.Nil(t, err)
+	origins := make(map[string]string)
+	assert.Nil(t, ApplyParamOverrides(params, overrides, origins))
+
+	assert.Equal(t, "set.example.com", params.GetValue("application.fqdn").Value)
+	assert.Equal(t, "node.kubernetes.io/instance-type", params.GetValue("application.nodePool.label").Value)
+	assert.Equal(t, "!!int", params.GetValue("database.port").Tag)
+	assert.Equal(t, "!!bool", params.GetValue("application.insecure").Tag)
+	assert.Equal(t, "!!str", params.GetValue("database.password").Tag)
+	assert.Equal(t, "--set", origins["application.fqdn"])
+	assert.Equal(t, "OPCTL_PARAM_APPLICATION__NODE_POOL__LABEL", origins["application.nodePool.label"])
+
+	_, err = ParamOverrides(nil, nil, []string{"application.fqdn"})
+	assert.NotNil(t, err)
+
+	overrides, err = ParamOverrides(nil, nil, []string{"application=example.com"})
+	assert.Nil(t, err)
+	assert.NotNil(t, ApplyParamOverrides(params, overrides, origins))
+}
+
+func TestMissingParams(t *testing.T) {
+	dir, err := ioutil.TempDir("", "opctl-params")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	basePath := filepath.Join(dir, "params.yaml")
+	base := "artifactRepository:\n  s3:\n    bucket: shared\n    secretKey:\n      secretRef:\n        env: AWS_SECRET_ACCESS_KEY\n"
+	assert.Nil(t, ioutil.WriteFile(basePath, []byte(base), 0644))
+
+	environmentPath := filepath.Join(dir, "prod.yaml")
+	assert.Nil(t, ioutil.WriteFile(environmentPath, []byte("application:\n  fqdn: prod.example.com\n"), 0644))
+
+	config := &opConfig.Config{
+		Spec: opConfig.ConfigSpec{
+			Params: opConfig.ParamsFiles{basePath, environmentPath},
+		},
+	}
+
+	params, err := LoadParams(config)
+	assert.Nil(t, err)
+
+	storageVars, err := LoadDynamicYamlFromString("artifactRepository:\n  s3:\n    bucket: onepanel\n    region: us-west-2\n    secretKey:\n      value: ''\n")
+	assert.Nil(t, err)
+	modelDbVars, err := LoadDynamicYamlFromString("application:\n  fqdn: example.com\nmodeldb:\n  storage: 20Gi\n")
+	assert.Nil(t, err)
+
+	environment, err := LoadDynamicYamlFromFile(environmentPath)
+	assert.Nil(t, err)
+	environment.Merge(MissingParams(params, storageVars, modelDbVars))
+	assert.Nil(t, environment.WriteToFile(environmentPath))
+
+	data, err := ioutil.ReadFile(environmentPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "application:\n  fqdn: prod.example.com\nartifactRepository:\n  s3:\n    region: us-west-2\nmodeldb:\n  storage: 20Gi\n", string(data))
+
+	data, err = ioutil.ReadFile(basePath)
+	assert.Nil(t, err)
+	assert.Equal(t, base, string(data))
+
+	params, err = LoadParams(config)
+	assert.Nil(t, err)
+	assert.Equal(t, "shared", params.GetValue("artifactRepository.s3.bucket").Value)
+	assert.Equal(t, "us-west-2", params.GetValue("artifactRepository.s3.region").Value)
+
+	// The defaults are not changed.
+	assert.Equal(t, "onepanel", storageVars.GetValue("artifactRepository.s3.bucket").Value)
+}
@@ -0,0 +1,296 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	PreflightPass = "pass"
+	PreflightWarn = "warn"
+	PreflightFail = "fail"
+
+	// MinKubernetesMinorVersion is the oldest 1.x release onepanel can be installed on.
+	MinKubernetesMinorVersion = 15
+	// MaxKubernetesMinorVersion is the newest 1.x release onepanel has been tested with.
+	MaxKubernetesMinorVersion = 18
+)
+
+// GPUResourceNames are the extended resources advertised by the nvidia and amd device plugins.
+var GPUResourceNames = []corev1.ResourceName{"nvidia.com/gpu", "amd.com/gpu"}
+
+// PreflightResult is the outcome of a single preflight check.
+type PreflightResult struct {
+	Name    string
+	Status  string // one of PreflightPass, PreflightWarn, PreflightFail
+	Message string
+}
+
+// PreflightOptions describes the deployment the cluster is checked against.
+type PreflightOptions struct {
+	Provider   string
+	Components []string // component paths from config.yaml, e.g. common/istio/base
+}
+
+func (o *PreflightOptions) hasComponent(name string) bool {
+	for _, component := range o.Components {
+		if component == name || strings.HasPrefix(component, name+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o *PreflightOptions) isLocalProvider() bool {
+	return o.Provider == "minikube" || o.Provider == "microk8s"
+}
+
+// providerName returns provider, or "the cluster" if it is not set.
+func providerName(provider string) string {
+	if provider == "" {
+		return "the cluster"
+	}
+
+	return provider
+}
+
+// PreflightReport is the collection of results from RunPreflightChecks.
+type PreflightReport struct {
+	Results []PreflightResult
+}
+
+func (r *PreflightReport) add(name, status, message string, args ...interface{}) {
+	r.Results = append(r.Results, PreflightResult{
+		Name:    name,
+		Status:  status,
+		Message: fmt.Sprintf(message, args...),
+	})
+}
+
+// HasFailures returns true if any check failed.
+func (r *PreflightReport) HasFailures() bool {
+	for _, result := range r.Results {
+		if result.Status == PreflightFail {
+			return true
+		}
+	}
+
+	return false
+}
+
+// String formats the report with one line per check.
+func (r *PreflightReport) String() string {
+	builder := &strings.Builder{}
+	for _, result := range r.Results {
+		fmt.Fprintf(builder, "[%v] %v: %v\n", result.Status, result.Name, result.Message)
+	}
+
+	return builder.String()
+}
+
+// RunPreflightChecks checks that the cluster can run a deployment described by options.
+// Errors talking to the cluster are reported as failed checks rather than returned.
+func RunPreflightChecks(client kubernetes.Interface, options PreflightOptions) *PreflightReport {
+	report := &PreflightReport{}
+
+	checkServerVersion(client, report)
+	checkStorageClasses(client, report)
+	checkClusterAdmin(client, report)
+	checkLoadBalancers(client, options, report)
+	if options.hasComponent("gpu-plugins") {
+		checkGPUNodes(client, report)
+	}
+	checkExistingInstalls(client, report)
+
+	return report
+}
+
+func checkServerVersion(client kubernetes.Interface, report *PreflightReport) {
+	name := "Kubernetes version"
+
+	info, err := client.Discovery().ServerVersion()
+	if err != nil {
+		report.add(name, PreflightFail, "unable to get server version: %v", err.Error())
+		return
+	}
+
+	major, majorErr := strconv.Atoi(strings.TrimSuffix(info.Major, "+"))
+	minor, minorErr := strconv.Atoi(strings.TrimSuffix(info.Minor, "+"))
+	if majorErr != nil || minorErr != nil {
+		report.add(name, PreflightWarn, "unable to parse server version %v", info.GitVersion)
+		return
+	}
+
+	if major != 1 || minor < MinKubernetesMinorVersion {
+		report.add(name, PreflightFail, "%v is not supported, 1.%v or newer is required", info.GitVersion, MinKubernetesMinorVersion)
+		return
+	}
+
+	if minor > MaxKubernetesMinorVersion {
+		report.add(name, PreflightWarn, "%v is newer than 1.%v, the latest tested version", info.GitVersion, MaxKubernetesMinorVersion)
+		return
+	}
+
+	report.add(name, PreflightPass, "%v", info.GitVersion)
+}
+
+func checkStorageClasses(client kubernetes.Interface, report *PreflightReport) {
+	name := "Default StorageClass"
+
+	storageClasses, err := client.StorageV1().StorageClasses().List(metav1.ListOptions{})
+	if err != nil {
+		report.add(name, PreflightFail, "unable to list storage classes: %v", err.Error())
+		return
+	}
+
+	for _, storageClass := range storageClasses.Items {
+		if storageClass.Annotations["storageclass.kubernetes.io/is-default-class"] == "true" ||
+			storageClass.Annotations["storageclass.beta.kubernetes.io/is-default-class"] == "true" {
+			report.add(name, PreflightPass, "%v", storageClass.Name)
+			return
+		}
+	}
+
+	if len(storageClasses.Items) == 0 {
+		report.add(name, PreflightFail, "no StorageClass found, persistent volumes can not be provisioned")
+		return
+	}
+
+	report.add(name, PreflightFail, "none of the %v StorageClasses is marked as default", len(storageClasses.Items))
+}
+
+func checkClusterAdmin(client kubernetes.Interface, report *PreflightReport) {
+	name := "Cluster admin"
+
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Verb:     "*",
+				Group:    "*",
+				Resource: "*",
+			},
+		},
+	}
+
+	result, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(review)
+	if err != nil {
+		report.add(name, PreflightFail, "unable to check permissions: %v", err.Error())
+		return
+	}
+
+	if !result.Status.Allowed {
+		report.add(name, PreflightFail, "the current user does not have cluster-admin permissions")
+		return
+	}
+
+	report.add(name, PreflightPass, "the current user has cluster-admin permissions")
+}
+
+func checkLoadBalancers(client kubernetes.Interface, options PreflightOptions, report *PreflightReport) {
+	name := "LoadBalancer"
+
+	if options.isLocalProvider() && !options.hasComponent("metallb") {
+		report.add(name, PreflightWarn, "%v does not provide LoadBalancer IPs, consider init with --enable-metallb", options.Provider)
+		return
+	}
+
+	services, err := client.CoreV1().Services("").List(metav1.ListOptions{})
+	if err != nil {
+		report.add(name, PreflightFail, "unable to list services: %v", err.Error())
+		return
+	}
+
+	loadBalancers := 0
+	pending := make([]string, 0)
+	for _, service := range services.Items {
+		if service.Spec.Type != corev1.ServiceTypeLoadBalancer {
+			continue
+		}
+
+		loadBalancers++
+		if len(service.Status.LoadBalancer.Ingress) == 0 {
+			pending = append(pending, service.Namespace+"/"+service.Name)
+		}
+	}
+
+	// A fresh cluster has no LoadBalancer services, so there is nothing that shows external IPs are provisioned.
+	if loadBalancers == 0 {
+		report.add(name, PreflightWarn, "unknown, there are no LoadBalancer services yet to check that %v provides external IPs", providerName(options.Provider))
+		return
+	}
+
+	if len(pending) != 0 {
+		report.add(name, PreflightWarn, "services have no external IP yet: %v", strings.Join(pending, ", "))
+		return
+	}
+
+	report.add(name, PreflightPass, "%v LoadBalancer service(s) have an external IP", loadBalancers)
+}
+
+func checkGPUNodes(client kubernetes.Interface, report *PreflightReport) {
+	name := "GPU nodes"
+
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		report.add(name, PreflightFail, "unable to list nodes: %v", err.Error())
+		return
+	}
+
+	gpus := resource.Quantity{}
+	gpuNodes := 0
+	for _, node := range nodes.Items {
+		hasGPU := false
+		for _, resourceName := range GPUResourceNames {
+			if quantity, ok := node.Status.Allocatable[resourceName]; ok && !quantity.IsZero() {
+				gpus.Add(quantity)
+				hasGPU = true
+			}
+		}
+
+		if hasGPU {
+			gpuNodes++
+		}
+	}
+
+	if gpuNodes == 0 {
+		report.add(name, PreflightWarn, "no node advertises GPUs; they are only listed once the device plugins run on GPU nodes")
+		return
+	}
+
+	report.add(name, PreflightPass, "%v GPU(s) on %v node(s)", gpus.String(), gpuNodes)
+}
+
+func checkExistingInstalls(client kubernetes.Interface, report *PreflightReport) {
+	name := "Existing installs"
+
+	namespaces, err := client.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		report.add(name, PreflightFail, "unable to list namespaces: %v", err.Error())
+		return
+	}
+
+	existing := make(map[string]bool)
+	for _, namespace := range namespaces.Items {
+		existing[namespace.Name] = true
+	}
+
+	if existing["onepanel"] {
+		report.add(name, PreflightPass, "found an existing onepanel install, it will be updated")
+		return
+	}
+
+	if existing["istio-system"] {
+		report.add(name, PreflightWarn, "istio-system already exists, an istio install not managed by onepanel will be modified")
+		return
+	}
+
+	report.add(name, PreflightPass, "no conflicting installs found")
+}
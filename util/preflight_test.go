@@ -0,0 +1,96 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func preflightResult(report *PreflightReport, name string) PreflightResult {
+	for _, result := range report.Results {
+		if result.Name == name {
+			return result
+		}
+	}
+
+	return PreflightResult{}
+}
+
+func TestRunPreflightChecks(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "standard",
+				Annotations: map[string]string{"storageclass.kubernetes.io/is-default-class": "true"},
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "gpu-node"},
+			Status: corev1.NodeStatus{
+				Allocatable: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("2")},
+			},
+		},
+	)
+	client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{
+		Major:      "1",
+		Minor:      "16+",
+		GitVersion: "v1.16.13",
+	}
+
+	report := RunPreflightChecks(client, PreflightOptions{
+		Provider:   "gke",
+		Components: []string{"common/istio/base", "gpu-plugins/base"},
+	})
+
+	assert.Equal(t, PreflightPass, preflightResult(report, "Kubernetes version").Status)
+	assert.Equal(t, PreflightPass, preflightResult(report, "Default StorageClass").Status)
+	assert.Equal(t, PreflightPass, preflightResult(report, "GPU nodes").Status)
+	assert.Equal(t, PreflightPass, preflightResult(report, "Existing installs").Status)
+	// There are no LoadBalancer services to check yet
+	assert.Equal(t, PreflightWarn, preflightResult(report, "LoadBalancer").Status)
+	// The fake clientset does not authorize anything
+	assert.Equal(t, PreflightFail, preflightResult(report, "Cluster admin").Status)
+	assert.True(t, report.HasFailures())
+}
+
+func TestRunPreflightChecks_UnsupportedVersion(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{
+		Major:      "1",
+		Minor:      "13",
+		GitVersion: "v1.13.0",
+	}
+
+	report := RunPreflightChecks(client, PreflightOptions{Provider: "minikube"})
+
+	assert.Equal(t, PreflightFail, preflightResult(report, "Kubernetes version").Status)
+	assert.Equal(t, PreflightFail, preflightResult(report, "Default StorageClass").Status)
+	assert.Equal(t, PreflightWarn, preflightResult(report, "LoadBalancer").Status)
+	assert.Equal(t, PreflightResult{}, preflightResult(report, "GPU nodes"))
+}
+
+func TestRunPreflightChecks_LoadBalancers(t *testing.T) {
+	loadBalancer := func(name string, ingress ...corev1.LoadBalancerIngress) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "istio-system"},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+			Status:     corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{Ingress: ingress}},
+		}
+	}
+
+	client := fake.NewSimpleClientset(loadBalancer("istio-ingressgateway", corev1.LoadBalancerIngress{IP: "10.0.0.1"}))
+	report := RunPreflightChecks(client, PreflightOptions{Provider: "gke"})
+	assert.Equal(t, PreflightPass, preflightResult(report, "LoadBalancer").Status)
+
+	client = fake.NewSimpleClientset(loadBalancer("istio-ingressgateway"))
+	report = RunPreflightChecks(client, PreflightOptions{Provider: "gke"})
+	assert.Equal(t, PreflightWarn, preflightResult(report, "LoadBalancer").Status)
+	assert.Contains(t, preflightResult(report, "LoadBalancer").Message, "istio-system/istio-ingressgateway")
+}
@@ -0,0 +1,177 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultImageMappingFileName is the file in the .onepanel directory of the environment the source and relocated images
+// are written to if imageRegistry.mappingFile is not set.
+const DefaultImageMappingFileName = "image-mapping.txt"
+
+var digestRegex = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// ImageRegistry is the imageRegistry section of the params. It relocates the container images of the build output
+// to a private registry.
+type ImageRegistry struct {
+	// Prefix replaces the registry of every image, e.g. registry.example.com/mirror turns
+	// quay.io/jetstack/cert-manager-controller:v0.12.0 into registry.example.com/mirror/jetstack/cert-manager-controller:v0.12.0
+	Prefix string `yaml:"prefix"`
+	// Overrides maps an image, with or without its tag, to the image to use instead. If the replacement has no tag,
+	// the tag of the image is kept. Overrides take precedence over Prefix.
+	Overrides map[string]string `yaml:"overrides"`
+	// Digests pins images, as they appear in the manifests, to a sha256 digest.
+	Digests map[string]string `yaml:"digests"`
+	// MappingFile is where the source and relocated images are written, one source=destination per line.
+	MappingFile string `yaml:"mappingFile"`
+}
+
+// LoadImageRegistry reads the imageRegistry section of the params. Nil is returned if there is none.
+func LoadImageRegistry(yamlFile *DynamicYaml) (*ImageRegistry, error) {
+	if !yamlFile.HasKey("imageRegistry") {
+		return nil, nil
+	}
+
+	registry := &ImageRegistry{}
+	if err := yamlFile.GetValue("imageRegistry").Decode(registry); err != nil {
+		return nil, fmt.Errorf("imageRegistry is badly formatted: %v", err.Error())
+	}
+
+	if err := registry.Validate(); err != nil {
+		return nil, err
+	}
+
+	return registry, nil
+}
+
+// Validate checks the prefix and digests.
+func (r *ImageRegistry) Validate() error {
+	if strings.Contains(r.Prefix, "@") || strings.HasSuffix(r.Prefix, ":") {
+		return fmt.Errorf("imageRegistry.prefix '%v' must be a registry and an optional path, e.g. registry.example.com/onepanel", r.Prefix)
+	}
+
+	for image, digest := range r.Digests {
+		if !digestRegex.MatchString(digest) {
+			return fmt.Errorf("imageRegistry.digests: '%v' of %v is not a sha256 digest, e.g. sha256:<64 hex characters>", digest, image)
+		}
+	}
+
+	return nil
+}
+
+// splitImage splits image into its name, its tag and its digest. The tag and digest may be empty.
+func splitImage(image string) (name, tag, digest string) {
+	name = image
+	if index := strings.Index(name, "@"); index >= 0 {
+		digest = name[index+1:]
+		name = name[:index]
+	}
+
+	// The tag comes after the last /, a : before it is the port of the registry.
+	if index := strings.LastIndex(name, ":"); index > strings.LastIndex(name, "/") {
+		tag = name[index+1:]
+		name = name[:index]
+	}
+
+	return
+}
+
+// joinImage is the reverse of splitImage.
+func joinImage(name, tag, digest string) string {
+	image := name
+	if tag != "" {
+		image += ":" + tag
+	}
+	if digest != "" {
+		image += "@" + digest
+	}
+
+	return image
+}
+
+// imagePath returns the name of the image without its registry, e.g. jetstack/cert-manager-controller for quay.io/jetstack/cert-manager-controller.
+// Like docker, the first part is a registry if it has a . or : in it, or is localhost.
+func imagePath(name string) string {
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[1]
+	}
+
+	return name
+}
+
+// Destination returns the image to mirror image to, without the pinned digest.
+func (r *ImageRegistry) Destination(image string) string {
+	name, tag, digest := splitImage(image)
+
+	override, ok := r.Overrides[image]
+	if !ok {
+		override, ok = r.Overrides[name]
+	}
+	if ok {
+		overrideName, overrideTag, overrideDigest := splitImage(override)
+		if overrideTag == "" && overrideDigest == "" {
+			return joinImage(overrideName, tag, digest)
+		}
+
+		return override
+	}
+
+	if r.Prefix == "" {
+		return image
+	}
+
+	return joinImage(strings.TrimSuffix(r.Prefix, "/")+"/"+imagePath(name), tag, digest)
+}
+
+// Relocate returns the image to use instead of image. It is the Destination, pinned to the digest set for image, if any.
+func (r *ImageRegistry) Relocate(image string) string {
+	destination := r.Destination(image)
+
+	digest, ok := r.Digests[image]
+	if !ok {
+		return destination
+	}
+
+	name, tag, _ := splitImage(destination)
+
+	return joinImage(name, tag, digest)
+}
+
+// RelocateContainerImages replaces the image of every container in object, a kubernetes resource.
+// The images that were changed are added to mapping, keyed by the original image, with their Destination as value.
+func (r *ImageRegistry) RelocateContainerImages(object map[string]interface{}, mapping map[string]string) {
+	walkContainers(object, func(container map[string]interface{}) {
+		image, ok := container["image"].(string)
+		if !ok || image == "" {
+			return
+		}
+
+		relocated := r.Relocate(image)
+		if relocated == image {
+			return
+		}
+
+		container["image"] = relocated
+		mapping[image] = r.Destination(image)
+	})
+}
+
+// WriteImageMapping writes mapping to path, one source=destination per line, sorted by source.
+func WriteImageMapping(path string, mapping map[string]string) error {
+	sources := make([]string, 0)
+	for source := range mapping {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	content := ""
+	for _, source := range sources {
+		content += source + "=" + mapping[source] + "\n"
+	}
+
+	return ioutil.WriteFile(path, []byte(content), 0644)
+}
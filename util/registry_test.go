@@ -0,0 +1,60 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageRegistry_Relocate(t *testing.T) {
+	digest := "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	registry := &ImageRegistry{
+		Prefix: "registry.example.com/mirror/",
+		Overrides: map[string]string{
+			"onepanel/core":     "registry.example.com/onepanel-core",
+			"busybox:1.31":      "registry.example.com/tools/busybox:stable",
+			"localhost:5000/ui": "registry.example.com/ui:v1",
+		},
+		Digests: map[string]string{
+			"quay.io/jetstack/cert-manager-controller:v0.12.0": digest,
+		},
+	}
+	assert.Nil(t, registry.Validate())
+
+	tests := map[string]string{
+		"onepanel/core:v0.10.0":        "registry.example.com/onepanel-core:v0.10.0",
+		"busybox:1.31":                 "registry.example.com/tools/busybox:stable",
+		"busybox:1.32":                 "registry.example.com/mirror/busybox:1.32",
+		"localhost:5000/ui:dev":        "registry.example.com/ui:v1",
+		"docker.io/library/nginx:1.17": "registry.example.com/mirror/library/nginx:1.17",
+		"quay.io/jetstack/cert-manager-controller:v0.12.0": "registry.example.com/mirror/jetstack/cert-manager-controller:v0.12.0@" + digest,
+	}
+	for image, expected := range tests {
+		assert.Equal(t, expected, registry.Relocate(image), image)
+	}
+
+	object := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"initContainers": []interface{}{
+						map[string]interface{}{"image": "busybox:1.31"},
+					},
+					"containers": []interface{}{
+						map[string]interface{}{"image": "quay.io/jetstack/cert-manager-controller:v0.12.0"},
+					},
+				},
+			},
+		},
+	}
+
+	mapping := make(map[string]string)
+	registry.RelocateContainerImages(object, mapping)
+	assert.Equal(t, map[string]string{
+		"busybox:1.31": "registry.example.com/tools/busybox:stable",
+		"quay.io/jetstack/cert-manager-controller:v0.12.0": "registry.example.com/mirror/jetstack/cert-manager-controller:v0.12.0",
+	}, mapping)
+
+	registry.Digests["busybox:1.31"] = "sha256:abc"
+	assert.NotNil(t, registry.Validate())
+}
@@ -0,0 +1,258 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretTokenPrefix starts the tokens that stand in for the secrets while the manifests are on disk.
+const secretTokenPrefix = "opctl-secret-"
+
+// vaultTimeout is how long a request to vault may take.
+const vaultTimeout = 30 * time.Second
+
+// SecretRef is a param value kept outside of the params files. In the params, it is a mapping with a single secretRef key,
+// e.g. secretKey: {secretRef: {env: AWS_SECRET_ACCESS_KEY}}. Exactly one of Env, File, Exec and Vault is set.
+type SecretRef struct {
+	Env   string   `yaml:"env"`   // environment variable
+	File  string   `yaml:"file"`  // file, the content is used as is
+	Exec  []string `yaml:"exec"`  // command and arguments, the output is used without its trailing newline
+	Vault string   `yaml:"vault"` // path#key of a KV secret, read from VAULT_ADDR with VAULT_TOKEN
+}
+
+// Secrets are the resolved values of the secret references of the params. In the params, each reference is replaced
+// by a token, so the values stay in memory while the manifests are prepared on disk. Use Reveal to get them back.
+type Secrets struct {
+	values map[string]string // keyed by token
+}
+
+// ConcealSecretRefs resolves each secret reference in params and replaces it with a token.
+func ConcealSecretRefs(params *DynamicYaml) (*Secrets, error) {
+	secrets := &Secrets{
+		values: make(map[string]string),
+	}
+
+	if params.node == nil || len(params.node.Content) == 0 {
+		return secrets, nil
+	}
+
+	if err := secrets.concealNode("", params.node.Content[0]); err != nil {
+		return nil, err
+	}
+
+	return secrets, nil
+}
+
+func (s *Secrets) concealNode(path string, node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := AppendDotFlatMapKeyFormatter(path, node.Content[i].Value)
+		value := node.Content[i+1]
+
+		if !isSecretRef(value) {
+			if err := s.concealNode(key, value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		ref := SecretRef{}
+		if err := value.Content[1].Decode(&ref); err != nil {
+			return fmt.Errorf("%v.secretRef is badly formatted: %v", key, err.Error())
+		}
+
+		secret, err := ref.Resolve()
+		if err != nil {
+			return fmt.Errorf("unable to resolve %v.secretRef: %v", key, err.Error())
+		}
+
+		token := secretToken(key)
+		s.values[token] = secret
+		node.Content[i+1] = &yaml.Node{
+			Kind:  yaml.ScalarNode,
+			Tag:   "!!str",
+			Value: token,
+		}
+	}
+
+	return nil
+}
+
+// isSecretRef returns true if node is a mapping with a single secretRef key.
+func isSecretRef(node *yaml.Node) bool {
+	return node.Kind == yaml.MappingNode && len(node.Content) == 2 && node.Content[0].Value == "secretRef"
+}
+
+// secretToken returns the token of the secret of key. Tokens only depend on the key, so builds stay the same,
+// and their length is a multiple of 3, so they are base64 encoded without padding.
+func secretToken(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return secretTokenPrefix + hex.EncodeToString(sum[:16])
+}
+
+// Resolve returns the value the reference points to.
+func (r *SecretRef) Resolve() (string, error) {
+	set := 0
+	for _, isSet := range []bool{r.Env != "", r.File != "", len(r.Exec) != 0, r.Vault != ""} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return "", fmt.Errorf("exactly one of env, file, exec and vault must be set")
+	}
+
+	switch {
+	case r.Env != "":
+		value, ok := os.LookupEnv(r.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %v is not set", r.Env)
+		}
+		return value, nil
+	case r.File != "":
+		content, err := ioutil.ReadFile(r.File)
+		if err != nil {
+			return "", err
+		}
+		return string(content), nil
+	case len(r.Exec) != 0:
+		return execSecret(r.Exec)
+	default:
+		return vaultSecret(r.Vault)
+	}
+}
+
+func execSecret(command []string) (string, error) {
+	stderr := &bytes.Buffer{}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stderr = stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%v failed: %v %v", command[0], err.Error(), strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(string(output), "\n"), "\r"), nil
+}
+
+// vaultSecret reads key of the KV secret at path, set as path#key, from the vault at VAULT_ADDR with VAULT_TOKEN.
+// Both versions of the KV secrets engine are supported, for version 2 the path includes data/, e.g. secret/data/onepanel#s3SecretKey.
+func vaultSecret(reference string) (string, error) {
+	parts := strings.SplitN(reference, "#", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("vault reference '%v' must be path#key, e.g. secret/data/onepanel#s3SecretKey", reference)
+	}
+	path, key := parts[0], parts[1]
+
+	address := os.Getenv("VAULT_ADDR")
+	if address == "" {
+		return "", fmt.Errorf("VAULT_ADDR is not set")
+	}
+
+	request, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(address, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("X-Vault-Token", os.Getenv("VAULT_TOKEN"))
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		request.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	client := &http.Client{Timeout: vaultTimeout}
+	response, err := client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %v for %v", response.Status, path)
+	}
+
+	secret := struct {
+		Data map[string]interface{} `json:"data"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&secret); err != nil {
+		return "", fmt.Errorf("unable to read vault response for %v: %v", path, err.Error())
+	}
+
+	// Version 2 nests the values in data, next to the metadata.
+	data := secret.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("vault secret %v has no key %v", path, key)
+	}
+	if text, ok := value.(string); ok {
+		return text, nil
+	}
+
+	return fmt.Sprintf("%v", value), nil
+}
+
+// Conceal keeps secret in memory and returns its token, for secrets that are not params, e.g. a loaded tls key.
+// key has to be unique, see secretToken.
+func (s *Secrets) Conceal(key, secret string) string {
+	token := secretToken(key)
+	s.values[token] = secret
+
+	return token
+}
+
+// Reveal replaces the tokens in value with their secret. A token that is base64 encoded is replaced with the
+// base64 encoded secret.
+func (s *Secrets) Reveal(value string) string {
+	if s == nil {
+		return value
+	}
+
+	for token, secret := range s.values {
+		value = strings.Replace(value, token, secret, -1)
+		value = strings.Replace(value, base64.StdEncoding.EncodeToString([]byte(token)), base64.StdEncoding.EncodeToString([]byte(secret)), -1)
+	}
+
+	return value
+}
+
+// RevealObject replaces the tokens in the strings of object, a kubernetes resource, see Reveal.
+func (s *Secrets) RevealObject(object map[string]interface{}) {
+	for key, value := range object {
+		object[key] = s.revealValue(value)
+	}
+}
+
+func (s *Secrets) revealValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case string:
+		return s.Reveal(typed)
+	case map[string]interface{}:
+		s.RevealObject(typed)
+	case []interface{}:
+		for i, item := range typed {
+			typed[i] = s.revealValue(item)
+		}
+	}
+
+	return value
+}
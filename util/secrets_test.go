@@ -0,0 +1,106 @@
+package util
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcealSecretRefs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "opctl-secrets")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	keyPath := filepath.Join(dir, "key.json")
+	assert.Nil(t, ioutil.WriteFile(keyPath, []byte("{\"type\": \"service_account\"}\n"), 0600))
+
+	assert.Nil(t, os.Setenv("OPCTL_TEST_SECRET_KEY", "s3-secret"))
+	defer os.Unsetenv("OPCTL_TEST_SECRET_KEY")
+
+	params, err := LoadDynamicYamlFromString(`artifactRepository:
+  s3:
+    accessKey:
+      secretRef:
+        exec: [echo, s3-access]
+    secretKey:
+      secretRef:
+        env: OPCTL_TEST_SECRET_KEY
+  gcs:
+    serviceAccountKey:
+      secretRef:
+        file: ` + keyPath + `
+`)
+	assert.Nil(t, err)
+
+	secrets, err := ConcealSecretRefs(params)
+	assert.Nil(t, err)
+
+	secretKey := params.GetValue("artifactRepository.s3.secretKey").Value
+	assert.True(t, strings.HasPrefix(secretKey, secretTokenPrefix))
+	assert.Equal(t, "s3-secret", secrets.Reveal(secretKey))
+	assert.Equal(t, "s3-access", secrets.Reveal(params.GetValue("artifactRepository.s3.accessKey").Value))
+	assert.Equal(t, "{\"type\": \"service_account\"}\n", secrets.Reveal(params.GetValue("artifactRepository.gcs.serviceAccountKey").Value))
+
+	object := map[string]interface{}{
+		"data": map[string]interface{}{
+			"secretKey": base64.StdEncoding.EncodeToString([]byte(secretKey)),
+		},
+		"stringData": []interface{}{"key: " + secretKey},
+	}
+	secrets.RevealObject(object)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("s3-secret")), object["data"].(map[string]interface{})["secretKey"])
+	assert.Equal(t, []interface{}{"key: s3-secret"}, object["stringData"])
+
+	params, err = LoadDynamicYamlFromString("secretKey:\n  secretRef:\n    env: OPCTL_TEST_SECRET_UNSET\n")
+	assert.Nil(t, err)
+	_, err = ConcealSecretRefs(params)
+	assert.NotNil(t, err)
+}
+
+func TestSecretRef_ResolveVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" || r.URL.Path != "/v1/secret/data/onepanel" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data": {"data": {"s3SecretKey": "vault-secret"}, "metadata": {"version": 1}}}`))
+	}))
+	defer server.Close()
+
+	assert.Nil(t, os.Setenv("VAULT_ADDR", server.URL))
+	defer os.Unsetenv("VAULT_ADDR")
+	assert.Nil(t, os.Setenv("VAULT_TOKEN", "token"))
+	defer os.Unsetenv("VAULT_TOKEN")
+
+	ref := &SecretRef{Vault: "secret/data/onepanel#s3SecretKey"}
+	value, err := ref.Resolve()
+	assert.Nil(t, err)
+	assert.Equal(t, "vault-secret", value)
+
+	ref = &SecretRef{Vault: "secret/data/onepanel#missing"}
+	_, err = ref.Resolve()
+	assert.NotNil(t, err)
+
+	ref = &SecretRef{Vault: "secret/data/other#s3SecretKey"}
+	_, err = ref.Resolve()
+	assert.NotNil(t, err)
+}
+
+func TestDynamicYaml_OverrideSecretRef(t *testing.T) {
+	base, err := LoadDynamicYamlFromString("secretKey:\n  secretRef:\n    env: AWS_SECRET_ACCESS_KEY\n")
+	assert.Nil(t, err)
+	secrets, err := LoadDynamicYamlFromString("secretKey:\n  secretRef:\n    file: ./secret-key\n")
+	assert.Nil(t, err)
+
+	base.Override(secrets)
+
+	assert.False(t, base.HasKey("secretKey.secretRef.env"))
+	assert.Equal(t, "./secret-key", base.GetValue("secretKey.secretRef.file").Value)
+}
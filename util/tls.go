@@ -0,0 +1,114 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+const (
+	// TLSExpiryWarningPeriod is how close to expiry a certificate has to be before we warn about it.
+	TLSExpiryWarningPeriod = 30 * 24 * time.Hour
+	// DefaultTLSSecretName is the secret the istio ingress gateway reads its certificate from.
+	DefaultTLSSecretName = "istio-ingressgateway-certs"
+	// TLSSecretNamespace is the namespace of the istio ingress gateway.
+	TLSSecretNamespace = "istio-system"
+)
+
+// TLSCertificate is a PEM encoded certificate and key pair.
+type TLSCertificate struct {
+	CertPEM []byte
+	KeyPEM  []byte
+	Leaf    *x509.Certificate
+}
+
+// LoadTLSCertificate loads the certificate and key and makes sure the key belongs to the certificate.
+// cert and key are paths to PEM files, or the PEM data itself, e.g. when the param is a secretRef.
+func LoadTLSCertificate(cert, key string) (*TLSCertificate, error) {
+	certPEM, err := readPEM(cert)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read tls certificate: %v", err.Error())
+	}
+
+	keyPEM, err := readPEM(key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read tls key: %v", err.Error())
+	}
+
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tls certificate/key pair: %v", err.Error())
+	}
+
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse tls certificate: %v", err.Error())
+	}
+
+	return &TLSCertificate{
+		CertPEM: certPEM,
+		KeyPEM:  keyPEM,
+		Leaf:    leaf,
+	}, nil
+}
+
+// Verify checks that the certificate is currently valid and covers fqdn as well as the
+// wildcard domain workspaces are served from, e.g. *.example.com for app.example.com.
+// Certificates that expire within TLSExpiryWarningPeriod produce a warning instead of an error.
+func (t *TLSCertificate) Verify(fqdn string, now time.Time) (warnings []string, err error) {
+	if now.Before(t.Leaf.NotBefore) {
+		return nil, fmt.Errorf("tls certificate is not valid until %v", t.Leaf.NotBefore.Format(time.RFC3339))
+	}
+
+	if now.After(t.Leaf.NotAfter) {
+		return nil, fmt.Errorf("tls certificate expired on %v", t.Leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	if err := t.Leaf.VerifyHostname(fqdn); err != nil {
+		return nil, fmt.Errorf("tls certificate does not cover %v: %v", fqdn, err.Error())
+	}
+
+	wildcard := GetWildCardDNS(fqdn)
+	probeHost := strings.Replace(wildcard, "*", "opctl-tls-check", 1)
+	if err := t.Leaf.VerifyHostname(probeHost); err != nil {
+		return nil, fmt.Errorf("tls certificate does not cover the wildcard domain %v", wildcard)
+	}
+
+	if t.Leaf.NotAfter.Sub(now) < TLSExpiryWarningPeriod {
+		days := int(t.Leaf.NotAfter.Sub(now).Hours() / 24)
+		warnings = append(warnings, fmt.Sprintf("tls certificate expires in %v day(s), on %v", days, t.Leaf.NotAfter.Format(time.RFC3339)))
+	}
+
+	return warnings, nil
+}
+
+// SecretYaml returns a kubernetes.io/tls Secret holding the certificate and key.
+// The key is concealed in secrets, so the Secret can be written to the manifests cache;
+// Secrets.RevealObject puts the key back when the resource is generated.
+func (t *TLSCertificate) SecretYaml(name, namespace string, secrets *Secrets) string {
+	keyToken := secrets.Conceal(namespace+"/"+name+"/tls.key", string(t.KeyPEM))
+
+	return fmt.Sprintf(`apiVersion: v1
+kind: Secret
+metadata:
+  name: %v
+  namespace: %v
+type: kubernetes.io/tls
+data:
+  tls.crt: %v
+  tls.key: %v
+`, name, namespace, base64.StdEncoding.EncodeToString(t.CertPEM), base64.StdEncoding.EncodeToString([]byte(keyToken)))
+}
+
+// readPEM returns value if it is PEM data, otherwise the content of the file at value.
+func readPEM(value string) ([]byte, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		return []byte(value), nil
+	}
+
+	return ioutil.ReadFile(value)
+}
@@ -0,0 +1,115 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func writeTestCertificate(t *testing.T, dir string, notAfter time.Time, dnsNames ...string) (certPath, keyPath string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	certPath = filepath.Join(dir, "tls.crt")
+	keyPath = filepath.Join(dir, "tls.key")
+	assert.Nil(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+
+	return
+}
+
+func TestTLSCertificate_Verify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "opctl-tls")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	certPath, keyPath := writeTestCertificate(t, dir, time.Now().Add(90*24*time.Hour), "*.example.com")
+	certificate, err := LoadTLSCertificate(certPath, keyPath)
+	assert.Nil(t, err)
+
+	warnings, err := certificate.Verify("app.example.com", time.Now())
+	assert.Nil(t, err)
+	assert.Empty(t, warnings)
+
+	_, err = certificate.Verify("app.other.com", time.Now())
+	assert.NotNil(t, err)
+
+	warnings, err = certificate.Verify("app.example.com", time.Now().Add(80*24*time.Hour))
+	assert.Nil(t, err)
+	assert.Len(t, warnings, 1)
+
+	_, err = certificate.Verify("app.example.com", time.Now().Add(100*24*time.Hour))
+	assert.NotNil(t, err)
+}
+
+func TestTLSCertificate_VerifyWithoutWildcard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "opctl-tls")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	certPath, keyPath := writeTestCertificate(t, dir, time.Now().Add(90*24*time.Hour), "app.example.com")
+	certificate, err := LoadTLSCertificate(certPath, keyPath)
+	assert.Nil(t, err)
+
+	_, err = certificate.Verify("app.example.com", time.Now())
+	assert.NotNil(t, err)
+}
+
+func TestTLSCertificate_SecretYaml(t *testing.T) {
+	dir, err := ioutil.TempDir("", "opctl-tls")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	certPath, keyPath := writeTestCertificate(t, dir, time.Now().Add(90*24*time.Hour), "*.example.com")
+	keyPEM, err := ioutil.ReadFile(keyPath)
+	assert.Nil(t, err)
+
+	params, err := LoadDynamicYamlFromString("application:\n  tls:\n    cert: " + certPath + "\n    key:\n      secretRef:\n        file: " + keyPath + "\n")
+	assert.Nil(t, err)
+
+	secrets, err := ConcealSecretRefs(params)
+	assert.Nil(t, err)
+
+	certificate, err := LoadTLSCertificate(secrets.Reveal(params.GetValue("application.tls.cert").Value), secrets.Reveal(params.GetValue("application.tls.key").Value))
+	assert.Nil(t, err)
+	assert.Equal(t, keyPEM, certificate.KeyPEM)
+
+	secretPath := filepath.Join(dir, "tls-secret.yaml")
+	assert.Nil(t, ioutil.WriteFile(secretPath, []byte(certificate.SecretYaml(DefaultTLSSecretName, TLSSecretNamespace, secrets)), 0600))
+
+	data, err := ioutil.ReadFile(secretPath)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), string(keyPEM))
+	assert.NotContains(t, string(data), base64.StdEncoding.EncodeToString(keyPEM))
+
+	object := make(map[string]interface{})
+	assert.Nil(t, yaml.Unmarshal(data, &object))
+	secrets.RevealObject(object)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(keyPEM), object["data"].(map[string]interface{})["tls.key"])
+}